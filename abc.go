@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// ABC computes the ABC size metric of every function in the node. The ABC
+// metric is a vector of the number of assignments, branches (function calls)
+// and conditions of a piece of code, usually reported as its magnitude
+// sqrt(A² + B² + C²). It was proposed by Jerry Fitzpatrick in 1997. For a
+// formal description see: https://en.wikipedia.org/wiki/ABC_Software_Metric
+//
+// This implementation counts one of the following UAST nodes:
+// * Assignments: nodes with the Assignment role that are not the Left or
+//   Right side of it, and Increment | Decrement operators
+// * Branches: nodes with the Call role that are not just the Callee,
+//   Argument or Receiver of a call
+// * Conditions: Boolean operators, comparison operators (Equal | Identical |
+//   LessThan | LessThanOrEqual | GreaterThan | GreaterThanOrEqual |
+//   Relational) and nodes with the Condition role that don't contain any of
+//   those operators, which covers else-less conditions such as `if x` and
+//   drivers that don't annotate operators.
+//
+// Functions nested in other functions also add to the count of the
+// enclosing one, as it happens with CyclomaticComplexity.
+type ABC struct{}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "abc",
+		Description: "Run ABC size metric calculation",
+		New:         func(*ToolEnv) (Tooler, error) { return ABC{}, nil },
+	})
+}
+
+type ABCData struct {
+	Name        string
+	Assignments int
+	Branches    int
+	Conditions  int
+}
+
+func (abc ABC) Exec(n *uast.Node) error {
+	for _, data := range ABCSize(n) {
+		fmt.Print(data)
+	}
+	return nil
+}
+
+// Magnitude returns the length of the ABC vector.
+func (ad *ABCData) Magnitude() float64 {
+	a, b, c := float64(ad.Assignments), float64(ad.Branches), float64(ad.Conditions)
+	return math.Sqrt(a*a + b*b + c*c)
+}
+
+func (ad *ABCData) String() string {
+	return fmt.Sprintf("FuncName:%s, ABC:<%d,%d,%d>, Magnitude:%.2f\n",
+		ad.Name, ad.Assignments, ad.Branches, ad.Conditions, ad.Magnitude())
+}
+
+// ABCSize computes the ABC metric of the functions in a *uast.Node.
+func ABCSize(n *uast.Node) []*ABCData {
+	var result []*ABCData
+	for _, function := range Functions(n) {
+		data := abcVector(function.Node)
+		data.Name = function.Name
+		result = append(result, data)
+	}
+	return result
+}
+
+func abcVector(n *uast.Node) *ABCData {
+	data := &ABCData{}
+
+	iter := uast.NewOrderPathIter(uast.NewPath(n))
+
+	for {
+		p := iter.Next()
+		if p.IsEmpty() {
+			break
+		}
+		n := p.Node()
+		roles := make(map[uast.Role]bool)
+		for _, r := range n.Roles {
+			roles[r] = true
+		}
+		if isAssignment(roles) {
+			data.Assignments++
+		}
+		if isBranch(n, roles) {
+			data.Branches++
+		}
+		if isConditionOperator(roles) || roles[uast.Condition] && !containsConditionOperator(n) {
+			data.Conditions++
+		}
+	}
+	return data
+}
+
+func isAssignment(roles map[uast.Role]bool) bool {
+	return roles[uast.Assignment] && !roles[uast.Left] && !roles[uast.Right] ||
+		roles[uast.Operator] && (roles[uast.Increment] || roles[uast.Decrement])
+}
+
+func isBranch(n *uast.Node, roles map[uast.Role]bool) bool {
+	if !roles[uast.Call] || roles[uast.Callee] {
+		return false
+	}
+	// a call used as an argument or receiver of another call has the
+	// Argument or Receiver roles too, but unlike the plain arguments
+	// it has a callee
+	return !roles[uast.Argument] && !roles[uast.Receiver] ||
+		len(childrenOfRoles(n, []uast.Role{uast.Callee}, nil)) > 0
+}
+
+func isConditionOperator(roles map[uast.Role]bool) bool {
+	return roles[uast.Operator] && (roles[uast.Boolean] ||
+		roles[uast.Equal] || roles[uast.Identical] ||
+		roles[uast.LessThan] || roles[uast.LessThanOrEqual] ||
+		roles[uast.GreaterThan] || roles[uast.GreaterThanOrEqual] ||
+		roles[uast.Relational])
+}
+
+func containsConditionOperator(n *uast.Node) bool {
+	roles := make(map[uast.Role]bool)
+	for _, r := range n.Roles {
+		roles[r] = true
+	}
+	if isConditionOperator(roles) {
+		return true
+	}
+	for _, child := range n.Children {
+		if containsConditionOperator(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestABCSize(t *testing.T) {
+	require := require.New(t)
+
+	// x = foo(bar(a), b); if (x > 1 && y) { x++ } else if (z) {}
+	body := &uast.Node{InternalType: "body", Roles: []uast.Role{uast.Function, uast.Body}, Children: []*uast.Node{
+		{InternalType: "assign", Roles: []uast.Role{uast.Expression, uast.Assignment, uast.Operator}, Children: []*uast.Node{
+			{InternalType: "x", Roles: []uast.Role{uast.Identifier, uast.Assignment, uast.Left}, Token: "x"},
+			{InternalType: "call", Roles: []uast.Role{uast.Expression, uast.Call, uast.Assignment, uast.Right}, Children: []*uast.Node{
+				{InternalType: "foo", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Callee}, Token: "foo"},
+				{InternalType: "call", Roles: []uast.Role{uast.Expression, uast.Call, uast.Argument}, Children: []*uast.Node{
+					{InternalType: "bar", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Callee}, Token: "bar"},
+					{InternalType: "a", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Argument}, Token: "a"},
+				}},
+				{InternalType: "b", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Argument}, Token: "b"},
+			}},
+		}},
+		{InternalType: "if", Roles: []uast.Role{uast.Statement, uast.If}, Children: []*uast.Node{
+			{InternalType: "and", Roles: []uast.Role{uast.If, uast.Condition, uast.Operator, uast.Boolean, uast.And}, Children: []*uast.Node{
+				{InternalType: "gt", Roles: []uast.Role{uast.Expression, uast.Operator, uast.GreaterThan}, Children: []*uast.Node{
+					{InternalType: "x", Roles: []uast.Role{uast.Identifier}, Token: "x"},
+				}},
+				{InternalType: "y", Roles: []uast.Role{uast.Identifier}, Token: "y"},
+			}},
+			{InternalType: "then", Roles: []uast.Role{uast.If, uast.Then}, Children: []*uast.Node{
+				{InternalType: "inc", Roles: []uast.Role{uast.Expression, uast.Operator, uast.Increment}},
+			}},
+			{InternalType: "else", Roles: []uast.Role{uast.If, uast.Else}, Children: []*uast.Node{
+				{InternalType: "if", Roles: []uast.Role{uast.Statement, uast.If}, Children: []*uast.Node{
+					{InternalType: "z", Roles: []uast.Role{uast.If, uast.Condition, uast.Identifier}, Token: "z"},
+				}},
+			}},
+		}},
+	}}
+	n := &uast.Node{InternalType: "module", Children: []*uast.Node{
+		{InternalType: "func", Roles: []uast.Role{uast.Function, uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "name", Roles: []uast.Role{uast.Function, uast.Name}, Token: "f"},
+			body,
+		}},
+	}}
+
+	result := ABCSize(n)
+	require.Equal([]*ABCData{{Name: "f", Assignments: 2, Branches: 2, Conditions: 3}}, result)
+	require.InDelta(4.12, result[0].Magnitude(), 0.01)
+}
+
+func TestABCRealUAST(t *testing.T) {
+	require := require.New(t)
+
+	n := readFixture(t, "fixtures/npath/someFuncs.java.json")
+	result := ABCSize(n)
+	require.Len(result, 6)
+	require.Equal(&ABCData{Name: "minFunction", Assignments: 2, Branches: 0, Conditions: 1}, result[0])
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// CallGraph extracts the call graph of the functions declared in the
+// analyzed files and computes their fan-in, fan-out and Henry-Kafura
+// information flow complexity. For a formal description of the latter see
+// the original paper: https://doi.org/10.1109/TSE.1981.231113
+//
+// Functions are the Function, Declaration nodes and calls the nodes with the
+// Call role found under them. As the UAST carries no symbol information,
+// calls are resolved by name on a best-effort basis, trying in order:
+//   - a function qualified with the call receiver, e.g. `Code.min` for
+//     `Code.min(a, b)`
+//   - a function with that name declared in the same type
+//   - a function with that name declared in the same file
+//   - the only function with that name in the analyzed files
+//
+// Calls that can't be resolved are listed but don't add to the metrics.
+type CallGraph struct {
+	// Format is the output format: text, dot or json.
+	Format string
+}
+
+// CallGraphOptions are the options of CallGraph.
+type CallGraphOptions struct {
+	Format string `long:"format" description:"output format: text, dot or json" default:"text"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "callgraph",
+		Description: "Run call graph extraction",
+		Options:     func() interface{} { return &CallGraphOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*CallGraphOptions)
+			return CallGraph{Format: o.Format}, nil
+		},
+	})
+}
+
+type CallGraphData struct {
+	Functions []*CallGraphFunction `json:"functions"`
+	Calls     []*CallGraphCall     `json:"calls"`
+}
+
+type CallGraphFunction struct {
+	File string `json:"file"`
+	// Name is the name of the function qualified with the names of the
+	// types and functions enclosing it.
+	Name string `json:"name"`
+	Line int    `json:"line"`
+	// Length is the number of lines of the function.
+	Length int `json:"length"`
+	// FanIn is the number of distinct functions calling this one.
+	FanIn int `json:"fan_in"`
+	// FanOut is the number of distinct functions called by this one.
+	FanOut int `json:"fan_out"`
+	// InformationFlow is the Henry-Kafura complexity:
+	// Length * (FanIn * FanOut)².
+	InformationFlow int `json:"information_flow"`
+	// Unresolved are the names of the called functions that couldn't be
+	// found in the analyzed files.
+	Unresolved []string `json:"unresolved,omitempty"`
+
+	node  *uast.Node
+	name  string
+	scope string
+	sites []*callSite
+}
+
+// CallGraphCall is an edge of the call graph. Caller and Callee are indexes
+// in CallGraphData.Functions.
+type CallGraphCall struct {
+	Caller int `json:"caller"`
+	Callee int `json:"callee"`
+	// Count is the number of call sites.
+	Count int `json:"count"`
+}
+
+type callSite struct {
+	name     string
+	receiver string
+}
+
+func (cg CallGraph) Exec(n *uast.Node) error {
+	return cg.ExecFiles([]*File{{UAST: n}})
+}
+
+func (cg CallGraph) ExecFiles(files []*File) error {
+	data := CallGraphOf(files)
+	switch cg.Format {
+	case "", "text":
+		for _, f := range data.Functions {
+			fmt.Print(f)
+		}
+		return nil
+	case "dot":
+		return data.WriteDOT(os.Stdout)
+	case "json":
+		return data.WriteJSON(os.Stdout)
+	default:
+		return ErrUnknownFormat.New(cg.Format)
+	}
+}
+
+func (cf *CallGraphFunction) String() string {
+	return fmt.Sprintf("File:%s, FuncName:%s, FanIn:%d, FanOut:%d, InformationFlow:%d\n",
+		cf.File, cf.Name, cf.FanIn, cf.FanOut, cf.InformationFlow)
+}
+
+// WriteDOT writes the call graph in Graphviz DOT format.
+func (cd *CallGraphData) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph callgraph {"); err != nil {
+		return err
+	}
+	for i, f := range cd.Functions {
+		label := fmt.Sprintf("%s\n%s:%d", f.Name, f.File, f.Line)
+		if _, err := fmt.Fprintf(w, "\tf%d [label=%s];\n", i, dotQuote(label)); err != nil {
+			return err
+		}
+	}
+	for _, c := range cd.Calls {
+		if _, err := fmt.Fprintf(w, "\tf%d -> f%d [label=\"%d\"];\n", c.Caller, c.Callee, c.Count); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// WriteJSON writes the call graph as a JSON document.
+func (cd *CallGraphData) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cd)
+}
+
+// CallGraphOf extracts the call graph of the functions declared in the files.
+func CallGraphOf(files []*File) *CallGraphData {
+	data := &CallGraphData{}
+	for _, file := range files {
+		collectCallGraph(data, file.Path, file.UAST, nil, nil)
+	}
+
+	index := make(map[*CallGraphFunction]int)
+	for i, f := range data.Functions {
+		index[f] = i
+	}
+
+	edges := make(map[[2]int]*CallGraphCall)
+	for i, f := range data.Functions {
+		for _, site := range f.sites {
+			callee := resolveCall(data.Functions, f, site)
+			if callee == nil {
+				f.Unresolved = append(f.Unresolved, site.name)
+				continue
+			}
+
+			key := [2]int{i, index[callee]}
+			if edge, ok := edges[key]; ok {
+				edge.Count++
+				continue
+			}
+			edges[key] = &CallGraphCall{Caller: i, Callee: index[callee], Count: 1}
+			data.Calls = append(data.Calls, edges[key])
+			f.FanOut++
+			callee.FanIn++
+		}
+	}
+
+	for _, f := range data.Functions {
+		start, end := lineRange(f.node)
+		f.Line = int(start)
+		if start != 0 {
+			f.Length = int(end-start) + 1
+		}
+		flow := f.FanIn * f.FanOut
+		f.InformationFlow = f.Length * flow * flow
+	}
+	return data
+}
+
+// collectCallGraph walks the node recording the functions declared and the
+// calls made in them. scope are the names of the enclosing types and
+// functions.
+func collectCallGraph(data *CallGraphData, file string, n *uast.Node, scope []string, caller *CallGraphFunction) {
+	for _, child := range n.Children {
+		switch {
+		case isTypeDeclaration(child):
+			collectCallGraph(data, file, child, appendScope(scope, typeName(child)), caller)
+			continue
+		case isFunctionDeclaration(child):
+			name := functionName(child)
+			f := &CallGraphFunction{
+				File:  file,
+				Name:  strings.Join(appendScope(scope, name), "."),
+				node:  child,
+				name:  name,
+				scope: strings.Join(scope, "."),
+			}
+			data.Functions = append(data.Functions, f)
+			collectCallGraph(data, file, child, appendScope(scope, name), f)
+			continue
+		case caller != nil && isCall(child):
+			if name := calleeName(child); name != "" {
+				caller.sites = append(caller.sites, &callSite{name: name, receiver: receiverName(child)})
+			}
+		}
+		collectCallGraph(data, file, child, scope, caller)
+	}
+}
+
+func appendScope(scope []string, name string) []string {
+	return append(append([]string(nil), scope...), name)
+}
+
+func resolveCall(funcs []*CallGraphFunction, caller *CallGraphFunction, site *callSite) *CallGraphFunction {
+	var sameScope, sameFile, named []*CallGraphFunction
+	for _, f := range funcs {
+		if f.name != site.name {
+			continue
+		}
+		if site.receiver != "" && strings.HasSuffix("."+f.scope, "."+site.receiver) {
+			return f
+		}
+		if f.scope == caller.scope {
+			sameScope = append(sameScope, f)
+		}
+		if f.File == caller.File {
+			sameFile = append(sameFile, f)
+		}
+		named = append(named, f)
+	}
+
+	for _, candidates := range [][]*CallGraphFunction{sameScope, sameFile} {
+		if len(candidates) > 0 {
+			return candidates[0]
+		}
+	}
+	if len(named) == 1 {
+		return named[0]
+	}
+	return nil
+}
+
+func isCall(n *uast.Node) bool {
+	roles := make(map[uast.Role]bool)
+	for _, r := range n.Roles {
+		roles[r] = true
+	}
+	return isBranch(n, roles)
+}
+
+// receiverName returns the name of the object a call is made on, if any.
+func receiverName(call *uast.Node) string {
+	if receivers := childrenOfRoles(call, []uast.Role{uast.Receiver}, nil); len(receivers) > 0 {
+		return nameOf(receivers[0])
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func qualifiedCall(receiver, name string) *uast.Node {
+	call := ckCall(name)
+	call.Children = append(call.Children, &uast.Node{InternalType: "receiver", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Receiver}, Token: receiver})
+	return call
+}
+
+func TestCallGraph(t *testing.T) {
+	require := require.New(t)
+
+	m2 := ckMethod("m2", ckCall("m2"))
+	m2.StartPosition = &uast.Position{Line: 10}
+	m2.Children[2].Children[0].EndPosition = &uast.Position{Line: 12}
+
+	files := []*File{
+		{Path: "a.java", UAST: &uast.Node{Children: []*uast.Node{
+			ckClassNode("A", ckMethod("m1", ckCall("m2"), qualifiedCall("B", "helper"), ckCall("println")), m2),
+		}}},
+		{Path: "b.java", UAST: &uast.Node{Children: []*uast.Node{
+			ckClassNode("B", ckMethod("helper"), ckMethod("m1", ckCall("helper"), ckCall("helper"))),
+		}}},
+		{Path: "c.py", UAST: &uast.Node{Children: []*uast.Node{
+			ckMethod("run", ckCall("m1")),
+		}}},
+	}
+
+	data := CallGraphOf(files)
+	var names []string
+	for _, f := range data.Functions {
+		names = append(names, f.Name)
+	}
+	require.Equal([]string{"A.m1", "A.m2", "B.helper", "B.m1", "run"}, names)
+
+	require.Equal([]*CallGraphCall{
+		{Caller: 0, Callee: 1, Count: 1},
+		{Caller: 0, Callee: 2, Count: 1},
+		{Caller: 1, Callee: 1, Count: 1},
+		{Caller: 3, Callee: 2, Count: 2},
+	}, data.Calls)
+
+	var fanIn, fanOut []int
+	for _, f := range data.Functions {
+		fanIn = append(fanIn, f.FanIn)
+		fanOut = append(fanOut, f.FanOut)
+	}
+	require.Equal([]int{0, 2, 2, 0, 0}, fanIn)
+	require.Equal([]int{2, 1, 0, 1, 0}, fanOut)
+
+	require.Equal([]string{"println"}, data.Functions[0].Unresolved)
+	require.Equal([]string{"m1"}, data.Functions[4].Unresolved)
+
+	require.Equal(10, data.Functions[1].Line)
+	require.Equal(3, data.Functions[1].Length)
+	require.Equal(12, data.Functions[1].InformationFlow)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(data.WriteDOT(buf))
+	require.Contains(buf.String(), "\tf1 [label=\"A.m2\\na.java:10\"];\n")
+	require.Contains(buf.String(), "\tf3 -> f2 [label=\"2\"];\n")
+}
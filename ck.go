@@ -0,0 +1,287 @@
+package tools
+
+import (
+	"fmt"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// CK computes the Chidamber & Kemerer object-oriented metrics suite for
+// every type declared in the analyzed files. For a formal description see
+// the original paper: https://doi.org/10.1109/32.295895
+//
+// Types are the nodes with the Type and Declaration roles; their methods are
+// the Function, Declaration nodes found under them and their fields any other
+// declaration outside of those methods. Nested types are measured on their
+// own and don't add to the metrics of the enclosing one.
+//
+// Since the UAST carries no symbol information, types and methods are
+// matched by name over the analyzed file set, so the inheritance metrics
+// (DIT, NOC) and the coupling ones are only as accurate as that set is
+// complete, and two types sharing a name are taken as the same one.
+type CK struct{}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "ck",
+		Description: "Run CK object-oriented metrics suite",
+		New:         func(*ToolEnv) (Tooler, error) { return CK{}, nil },
+	})
+}
+
+type CKData struct {
+	File string
+	Name string
+	// WMC is the weighted methods per class, the sum of the cyclomatic
+	// complexity of its methods.
+	WMC int
+	// DIT is the depth of inheritance tree. Base types that are not
+	// declared in the analyzed files count as one level.
+	DIT int
+	// NOC is the number of children, the types directly inheriting from it.
+	NOC int
+	// CBO is the coupling between objects, the number of other types it
+	// references or that reference it.
+	CBO int
+	// RFC is the response for class, the number of distinct methods that
+	// can be executed in response to a message: its own methods plus the
+	// ones they call.
+	RFC int
+	// LCOM is the lack of cohesion in methods, the number of method pairs
+	// not sharing any field minus the number of pairs sharing some, or
+	// zero if negative.
+	LCOM int
+}
+
+func (ck CK) Exec(n *uast.Node) error {
+	return ck.ExecFiles([]*File{{UAST: n}})
+}
+
+func (ck CK) ExecFiles(files []*File) error {
+	for _, data := range CKMetrics(files) {
+		fmt.Print(data)
+	}
+	return nil
+}
+
+func (cd *CKData) String() string {
+	return fmt.Sprintf("File:%s, Class:%s, WMC:%d, DIT:%d, NOC:%d, CBO:%d, RFC:%d, LCOM:%d\n",
+		cd.File, cd.Name, cd.WMC, cd.DIT, cd.NOC, cd.CBO, cd.RFC, cd.LCOM)
+}
+
+// CKMetrics computes the CK metrics of the types declared in the files.
+func CKMetrics(files []*File) []*CKData {
+	var classes []*ckClass
+	byName := make(map[string]*ckClass)
+	for _, file := range files {
+		for _, decl := range deepChildrenOfRoles(file.UAST, []uast.Role{uast.Type, uast.Declaration}, []uast.Role{uast.Argument}) {
+			class := newCKClass(file.Path, decl)
+			classes = append(classes, class)
+			if _, ok := byName[class.name]; !ok {
+				byName[class.name] = class
+			}
+		}
+	}
+
+	children := make(map[*ckClass]int)
+	coupled := make(map[*ckClass]map[string]bool)
+	for _, class := range classes {
+		coupled[class] = make(map[string]bool)
+	}
+	for _, class := range classes {
+		for _, base := range class.bases {
+			if parent, ok := byName[base]; ok && parent != class {
+				children[parent]++
+			}
+		}
+		for ref := range class.refs {
+			if ref == class.name {
+				continue
+			}
+			coupled[class][ref] = true
+			if other, ok := byName[ref]; ok {
+				coupled[other][class.name] = true
+			}
+		}
+	}
+
+	var result []*CKData
+	for _, class := range classes {
+		result = append(result, &CKData{
+			File: class.file,
+			Name: class.name,
+			WMC:  class.wmc(),
+			DIT:  class.dit(byName),
+			NOC:  children[class],
+			CBO:  len(coupled[class]),
+			RFC:  class.rfc(),
+			LCOM: class.lcom(),
+		})
+	}
+	return result
+}
+
+type ckClass struct {
+	file    string
+	name    string
+	bases   []string
+	methods []*Function
+	fields  map[string]bool
+	// refs are the names of the types referenced by the class
+	refs map[string]bool
+	// calls are the names of the methods called by the class methods
+	calls map[string]bool
+}
+
+func newCKClass(file string, decl *uast.Node) *ckClass {
+	class := &ckClass{
+		file:   file,
+		name:   typeName(decl),
+		fields: make(map[string]bool),
+		refs:   make(map[string]bool),
+		calls:  make(map[string]bool),
+	}
+	class.collect(decl, false)
+	return class
+}
+
+// collect walks the members of the class, stopping at nested types.
+func (c *ckClass) collect(n *uast.Node, inMethod bool) {
+	for _, child := range n.Children {
+		switch {
+		case isTypeDeclaration(child):
+			continue
+		case !inMethod && isFunctionDeclaration(child):
+			c.methods = append(c.methods, &Function{Name: functionName(child), Node: child})
+			c.collect(child, true)
+			continue
+		case !inMethod && containsRoles(child, []uast.Role{uast.Base}, nil):
+			if base := nameOf(child); base != "" {
+				c.bases = append(c.bases, base)
+			}
+		case !inMethod && containsRoles(child, []uast.Role{uast.Declaration}, []uast.Role{uast.Function, uast.Argument}):
+			if field := declarationName(child); field != "" {
+				c.fields[field] = true
+			}
+		case inMethod && containsRoles(child, []uast.Role{uast.Call}, nil):
+			if callee := calleeName(child); callee != "" {
+				c.calls[callee] = true
+			}
+		}
+
+		if containsRoles(child, []uast.Role{uast.Type}, []uast.Role{uast.Primitive}) {
+			if ref := nameOf(child); ref != "" {
+				c.refs[ref] = true
+			}
+		}
+		c.collect(child, inMethod)
+	}
+}
+
+func (c *ckClass) wmc() int {
+	wmc := 0
+	for _, method := range c.methods {
+		wmc += cyclomaticComplexity(method.Node)
+	}
+	return wmc
+}
+
+func (c *ckClass) dit(byName map[string]*ckClass) int {
+	depth := 0
+	seen := map[*ckClass]bool{c: true}
+	for class := c; len(class.bases) > 0; {
+		depth++
+		parent, ok := byName[class.bases[0]]
+		if !ok || seen[parent] {
+			break
+		}
+		seen[parent] = true
+		class = parent
+	}
+	return depth
+}
+
+func (c *ckClass) rfc() int {
+	response := make(map[string]bool)
+	for _, method := range c.methods {
+		response[method.Name] = true
+	}
+	for call := range c.calls {
+		response[call] = true
+	}
+	return len(response)
+}
+
+func (c *ckClass) lcom() int {
+	var accesses []map[string]bool
+	for _, method := range c.methods {
+		accesses = append(accesses, fieldAccesses(method.Node, c.fields))
+	}
+
+	disjoint, sharing := 0, 0
+	for i := range accesses {
+		for j := i + 1; j < len(accesses); j++ {
+			if intersects(accesses[i], accesses[j]) {
+				sharing++
+			} else {
+				disjoint++
+			}
+		}
+	}
+	if disjoint > sharing {
+		return disjoint - sharing
+	}
+	return 0
+}
+
+func fieldAccesses(n *uast.Node, fields map[string]bool) map[string]bool {
+	accessed := make(map[string]bool)
+	for _, id := range deepChildrenOfRoles(n, []uast.Role{uast.Identifier}, nil) {
+		if fields[id.Token] {
+			accessed[id.Token] = true
+		}
+	}
+	return accessed
+}
+
+func intersects(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
+
+func isTypeDeclaration(n *uast.Node) bool {
+	return containsRoles(n, []uast.Role{uast.Type, uast.Declaration}, []uast.Role{uast.Argument})
+}
+
+// typeName returns the name of a type declaration: the token of its child
+// with the Name role or, lacking that, of its first identifier child.
+func typeName(decl *uast.Node) string {
+	if names := childrenOfRoles(decl, []uast.Role{uast.Name}, nil); len(names) > 0 {
+		return nameOf(names[0])
+	}
+	return declarationName(decl)
+}
+
+// declarationName returns the token of the first identifier child of a
+// declaration.
+func declarationName(decl *uast.Node) string {
+	if ids := childrenOfRoles(decl, []uast.Role{uast.Identifier}, nil); len(ids) > 0 {
+		return ids[0].Token
+	}
+	return ""
+}
+
+// calleeName returns the name of the function called by a Call node.
+func calleeName(call *uast.Node) string {
+	if containsRoles(call, []uast.Role{uast.Callee}, nil) {
+		return nameOf(call)
+	}
+	if callees := childrenOfRoles(call, []uast.Role{uast.Callee}, nil); len(callees) > 0 {
+		return nameOf(callees[0])
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func ckMethod(name string, body ...*uast.Node) *uast.Node {
+	return &uast.Node{InternalType: "method", Roles: []uast.Role{uast.Function, uast.Declaration}, Children: []*uast.Node{
+		{InternalType: "name", Roles: []uast.Role{uast.Function, uast.Name}, Token: name},
+		{InternalType: "param", Roles: []uast.Role{uast.Function, uast.Argument, uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "type", Roles: []uast.Role{uast.Type, uast.Primitive}, Token: "int"},
+		}},
+		{InternalType: "body", Roles: []uast.Role{uast.Function, uast.Body}, Children: body},
+	}}
+}
+
+func ckClassNode(name string, members ...*uast.Node) *uast.Node {
+	children := []*uast.Node{{InternalType: "name", Roles: []uast.Role{uast.Expression, uast.Identifier}, Token: name}}
+	return &uast.Node{InternalType: "class", Roles: []uast.Role{uast.Type, uast.Declaration}, Children: append(children, members...)}
+}
+
+func ckField(name string) *uast.Node {
+	return &uast.Node{InternalType: "field", Roles: []uast.Role{uast.Declaration}, Children: []*uast.Node{
+		{InternalType: "type", Roles: []uast.Role{uast.Type, uast.Primitive}, Token: "int"},
+		{InternalType: "fragment", Roles: []uast.Role{uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "name", Roles: []uast.Role{uast.Identifier}, Token: name},
+		}},
+	}}
+}
+
+func ckIdent(name string) *uast.Node {
+	return &uast.Node{InternalType: "ident", Roles: []uast.Role{uast.Expression, uast.Identifier}, Token: name}
+}
+
+func ckCall(name string) *uast.Node {
+	return &uast.Node{InternalType: "call", Roles: []uast.Role{uast.Expression, uast.Call}, Children: []*uast.Node{
+		{InternalType: "callee", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Callee}, Token: name},
+	}}
+}
+
+func TestCKMetrics(t *testing.T) {
+	require := require.New(t)
+
+	base := ckClassNode("Base",
+		ckField("x"),
+		ckField("y"),
+		ckMethod("getX", ckIdent("x")),
+		ckMethod("getY", ckIdent("y")),
+		ckMethod("sum", ckIdent("x"), ckIdent("y"), &uast.Node{InternalType: "if", Roles: []uast.Role{uast.Statement, uast.If}}),
+		ckMethod("noop"),
+	)
+	derived := ckClassNode("Derived",
+		&uast.Node{InternalType: "superclass", Roles: []uast.Role{uast.Type, uast.Base}, Children: []*uast.Node{ckIdent("Base")}},
+		ckMethod("run", ckCall("getX"), ckCall("println")),
+	)
+	leaf := ckClassNode("Leaf",
+		&uast.Node{InternalType: "superclass", Roles: []uast.Role{uast.Type, uast.Base}, Children: []*uast.Node{ckIdent("Derived")}},
+		ckClassNode("Inner", ckMethod("inner")),
+	)
+
+	files := []*File{
+		{Path: "base.java", UAST: &uast.Node{InternalType: "file", Children: []*uast.Node{base}}},
+		{Path: "derived.java", UAST: &uast.Node{InternalType: "file", Children: []*uast.Node{derived, leaf}}},
+	}
+
+	expect := []*CKData{
+		{File: "base.java", Name: "Base", WMC: 5, DIT: 0, NOC: 1, CBO: 1, RFC: 4, LCOM: 2},
+		{File: "derived.java", Name: "Derived", WMC: 1, DIT: 1, NOC: 1, CBO: 2, RFC: 3, LCOM: 0},
+		{File: "derived.java", Name: "Leaf", WMC: 0, DIT: 2, NOC: 0, CBO: 1, RFC: 0, LCOM: 0},
+		{File: "derived.java", Name: "Inner", WMC: 1, DIT: 0, NOC: 0, CBO: 0, RFC: 1, LCOM: 0},
+	}
+	require.Equal(expect, CKMetrics(files))
+}
+
+func TestCKUnresolvedBase(t *testing.T) {
+	require := require.New(t)
+
+	class := ckClassNode("Widget",
+		&uast.Node{InternalType: "superclass", Roles: []uast.Role{uast.Type, uast.Base}, Children: []*uast.Node{ckIdent("Component")}},
+	)
+	result := CKMetrics([]*File{{UAST: &uast.Node{Children: []*uast.Node{class}}}})
+	require.Len(result, 1)
+	require.Equal(1, result[0].DIT)
+	require.Equal(1, result[0].CBO)
+}
+
+func TestCKRealUAST(t *testing.T) {
+	require := require.New(t)
+
+	n := readFixture(t, "fixtures/npath/someFuncs.java.json")
+	result := CKMetrics([]*File{{Path: "someFuncs.java", UAST: n}})
+	require.Len(result, 1)
+	require.Equal("Code", result[0].Name)
+	require.Equal(6, len(Functions(n)))
+	require.Equal(0, result[0].DIT)
+}
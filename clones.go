@@ -0,0 +1,397 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Clones finds structural code clones, functions and blocks whose UAST
+// subtrees have the same shape, in the analyzed files.
+//
+// Subtrees are compared by a hash of their normalized form: internal types,
+// roles, properties and tokens, but ignoring the tokens of identifiers and
+// literals and the properties of the latter, so renamed variables or
+// changed constants are still detected (Type-2 clones in the terms of Roy &
+// Cordy: https://research.cs.queensu.ca/TechReports/Reports/2007-541.pdf).
+// Comments are ignored.
+//
+// With a Similarity below 1, near-miss clones (Type-3) are found too,
+// measuring the similarity of two subtrees as in Baxter et al. "Clone
+// Detection Using Abstract Syntax Trees", 2S / (2S + L + R), where S is the
+// number of nodes whose normalized subtree appears in both, and L and R the
+// nodes only found in one of them.
+//
+// Functions are only compared with functions and blocks with blocks. Clones
+// contained in the members of a bigger group, such as the bodies of cloned
+// functions, are not reported.
+type Clones struct {
+	// MinSize is the minimum size, in UAST nodes, of the reported clones.
+	MinSize int
+	// Similarity is the minimum similarity, from 0 to 1, between two
+	// subtrees to be considered clones. Zero means 1, exact clones.
+	Similarity float64
+}
+
+// ClonesOptions are the options of Clones.
+type ClonesOptions struct {
+	MinSize    int     `long:"min-size" description:"minimum size of the clones in UAST nodes" default:"30"`
+	Similarity float64 `long:"similarity" description:"minimum similarity, from 0 to 1, of near-miss clones" default:"1"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "clones",
+		Description: "Run structural code clone detection",
+		Options:     func() interface{} { return &ClonesOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*ClonesOptions)
+			return Clones{MinSize: o.MinSize, Similarity: o.Similarity}, nil
+		},
+	})
+}
+
+type CloneGroup struct {
+	// Similarity is the lowest similarity between the clones of the group.
+	Similarity float64
+	Clones     []*Clone
+}
+
+type Clone struct {
+	File string
+	// Function is the name of the cloned function, or the one containing
+	// the cloned block.
+	Function string
+	// Block is false for whole functions.
+	Block bool
+	// StartLine and EndLine are the lines the clone spans, zero if unknown.
+	StartLine int
+	EndLine   int
+	// Size is the number of UAST nodes of the clone.
+	Size int
+}
+
+func (c Clones) Exec(n *uast.Node) error {
+	return c.ExecFiles([]*File{{UAST: n}})
+}
+
+func (c Clones) ExecFiles(files []*File) error {
+	for i, group := range c.Find(files) {
+		fmt.Printf("Group:%d, Clones:%d, Similarity:%.2f\n", i+1, len(group.Clones), group.Similarity)
+		for _, clone := range group.Clones {
+			fmt.Print("\t", clone)
+		}
+	}
+	return nil
+}
+
+func (c *Clone) String() string {
+	kind := "Function"
+	if c.Block {
+		kind = "Block in"
+	}
+	return fmt.Sprintf("File:%s, Lines:%d-%d, %s:%s, Size:%d\n",
+		c.File, c.StartLine, c.EndLine, kind, c.Function, c.Size)
+}
+
+// Find returns the groups of clones in the files, biggest first.
+func (c Clones) Find(files []*File) []*CloneGroup {
+	similarity := c.Similarity
+	if similarity <= 0 || similarity > 1 {
+		similarity = 1
+	}
+
+	h := &cloneHasher{hashes: make(map[*uast.Node]uint64), sizes: make(map[*uast.Node]int)}
+	var candidates []*cloneCandidate
+	for i, file := range files {
+		h.hash(file.UAST)
+		collector := &cloneCollector{hasher: h, file: i, path: file.Path, minSize: c.MinSize}
+		collector.collect(file.UAST, "")
+		candidates = append(candidates, collector.candidates...)
+	}
+
+	var groups []*cloneCandidateGroup
+	if similarity == 1 {
+		groups = exactClones(candidates, h)
+	} else {
+		groups = similarClones(candidates, similarity, h)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].members[0].clone.Size > groups[j].members[0].clone.Size
+	})
+
+	var result []*CloneGroup
+	var reported []*cloneCandidate
+	for _, group := range groups {
+		covered := true
+		for _, member := range group.members {
+			if !containedInAny(member, reported) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+
+		reported = append(reported, group.members...)
+		cg := &CloneGroup{Similarity: group.similarity}
+		for _, member := range group.members {
+			cg.Clones = append(cg.Clones, member.clone)
+		}
+		result = append(result, cg)
+	}
+	return result
+}
+
+type cloneCandidate struct {
+	clone *Clone
+	node  *uast.Node
+	file  int
+	// pre and post are the preorder numbers of the node and its last
+	// descendant, to tell if a candidate contains another
+	pre, post int
+}
+
+func (cc *cloneCandidate) contains(other *cloneCandidate) bool {
+	return cc.file == other.file && cc.pre <= other.pre && other.post <= cc.post
+}
+
+func containedInAny(c *cloneCandidate, candidates []*cloneCandidate) bool {
+	for _, other := range candidates {
+		if other.contains(c) {
+			return true
+		}
+	}
+	return false
+}
+
+type cloneCandidateGroup struct {
+	members    []*cloneCandidate
+	similarity float64
+}
+
+type cloneCollector struct {
+	hasher     *cloneHasher
+	file       int
+	path       string
+	minSize    int
+	counter    int
+	candidates []*cloneCandidate
+}
+
+// collect numbers the nodes in preorder and gathers the functions and
+// blocks big enough to be reported.
+func (cc *cloneCollector) collect(n *uast.Node, function string) {
+	cc.counter++
+	pre := cc.counter
+
+	isFunction := isFunctionDeclaration(n)
+	isBlock := !isFunction && containsRoles(n, []uast.Role{uast.Block}, nil)
+	var candidate *cloneCandidate
+	if (isFunction || isBlock) && cc.hasher.sizes[n] >= cc.minSize {
+		start, end := lineRange(n)
+		name := function
+		if isFunction {
+			name = functionName(n)
+		}
+		candidate = &cloneCandidate{
+			clone: &Clone{
+				File:      cc.path,
+				Function:  name,
+				Block:     isBlock,
+				StartLine: int(start),
+				EndLine:   int(end),
+				Size:      cc.hasher.sizes[n],
+			},
+			node: n,
+			file: cc.file,
+			pre:  pre,
+		}
+		cc.candidates = append(cc.candidates, candidate)
+	}
+
+	if isFunction {
+		function = functionName(n)
+	}
+	for _, child := range n.Children {
+		cc.collect(child, function)
+	}
+	if candidate != nil {
+		candidate.post = cc.counter
+	}
+}
+
+func exactClones(candidates []*cloneCandidate, h *cloneHasher) []*cloneCandidateGroup {
+	byHash := make(map[uint64]*cloneCandidateGroup)
+	var groups []*cloneCandidateGroup
+	for _, candidate := range candidates {
+		hash := h.hashes[candidate.node]
+		group, ok := byHash[hash]
+		if !ok {
+			group = &cloneCandidateGroup{similarity: 1}
+			byHash[hash] = group
+			groups = append(groups, group)
+		}
+		group.members = append(group.members, candidate)
+	}
+
+	var result []*cloneCandidateGroup
+	for _, group := range groups {
+		if len(group.members) > 1 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+func similarClones(candidates []*cloneCandidate, threshold float64, h *cloneHasher) []*cloneCandidateGroup {
+	sorted := append([]*cloneCandidate(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].clone.Size < sorted[j].clone.Size
+	})
+
+	shingles := make(map[*cloneCandidate]map[uint64]int)
+	for _, c := range sorted {
+		bag := make(map[uint64]int)
+		h.shingles(c.node, bag)
+		shingles[c] = bag
+	}
+
+	parent := make(map[*cloneCandidate]*cloneCandidate)
+	var find func(*cloneCandidate) *cloneCandidate
+	find = func(c *cloneCandidate) *cloneCandidate {
+		if p, ok := parent[c]; ok && p != c {
+			parent[c] = find(p)
+			return parent[c]
+		}
+		return c
+	}
+	minSimilarity := make(map[*cloneCandidate]float64)
+
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			total := float64(a.clone.Size + b.clone.Size)
+			if 2*float64(a.clone.Size)/total < threshold {
+				break
+			}
+			if a.clone.Block != b.clone.Block || a.contains(b) || b.contains(a) {
+				continue
+			}
+
+			shared := 0
+			for hash, count := range shingles[a] {
+				if other := shingles[b][hash]; other < count {
+					shared += other
+				} else {
+					shared += count
+				}
+			}
+			similarity := 2 * float64(shared) / total
+			if similarity < threshold {
+				continue
+			}
+
+			ra, rb := find(a), find(b)
+			low := similarity
+			for _, r := range []*cloneCandidate{ra, rb} {
+				if s, ok := minSimilarity[r]; ok && s < low {
+					low = s
+				}
+			}
+			if ra != rb {
+				parent[rb] = ra
+			}
+			minSimilarity[ra] = low
+		}
+	}
+
+	byRoot := make(map[*cloneCandidate]*cloneCandidateGroup)
+	var groups []*cloneCandidateGroup
+	for _, c := range candidates {
+		root := find(c)
+		if _, ok := minSimilarity[root]; !ok {
+			continue
+		}
+		group, ok := byRoot[root]
+		if !ok {
+			group = &cloneCandidateGroup{similarity: minSimilarity[root]}
+			byRoot[root] = group
+			groups = append(groups, group)
+		}
+		group.members = append(group.members, c)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group.members, func(i, j int) bool {
+			return group.members[i].clone.Size > group.members[j].clone.Size
+		})
+	}
+	return groups
+}
+
+// cloneHasher computes the normalized hash and the size of every subtree.
+type cloneHasher struct {
+	hashes map[*uast.Node]uint64
+	sizes  map[*uast.Node]int
+}
+
+func (h *cloneHasher) hash(n *uast.Node) uint64 {
+	digest := fnv.New64a()
+	write := func(s string) {
+		digest.Write([]byte(s))
+		digest.Write([]byte{0})
+	}
+
+	write(n.InternalType)
+	roles := append([]uast.Role(nil), n.Roles...)
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	for _, r := range roles {
+		write(r.String())
+	}
+
+	variable := containsRoles(n, []uast.Role{uast.Identifier}, nil) || containsRoles(n, []uast.Role{uast.Literal}, nil)
+	if !variable {
+		write(n.Token)
+		keys := make([]string, 0, len(n.Properties))
+		for k := range n.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			write(k)
+			write(n.Properties[k])
+		}
+	}
+
+	size := 1
+	buf := make([]byte, 8)
+	for _, child := range n.Children {
+		if containsRoles(child, []uast.Role{uast.Comment}, nil) {
+			continue
+		}
+		binary.LittleEndian.PutUint64(buf, h.hash(child))
+		digest.Write(buf)
+		size += h.sizes[child]
+	}
+
+	sum := digest.Sum64()
+	h.hashes[n] = sum
+	h.sizes[n] = size
+	return sum
+}
+
+// shingles adds the hashes of every subtree under n to bag.
+func (h *cloneHasher) shingles(n *uast.Node, bag map[uint64]int) {
+	if containsRoles(n, []uast.Role{uast.Comment}, nil) {
+		return
+	}
+	bag[h.hashes[n]]++
+	for _, child := range n.Children {
+		h.shingles(child, bag)
+	}
+}
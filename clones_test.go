@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// cloneFunc builds `name(p) { if (p > limit) { a = p; b = a; c = b } }`,
+// optionally adding a call at the end of the if body.
+func cloneFunc(name, p, limit string, line uint32, extra bool) *uast.Node {
+	assign := func(left, right string) *uast.Node {
+		return &uast.Node{InternalType: "Assignment", Roles: []uast.Role{uast.Expression, uast.Assignment}, Children: []*uast.Node{
+			{InternalType: "SimpleName", Roles: []uast.Role{uast.Identifier, uast.Left}, Token: left},
+			{InternalType: "SimpleName", Roles: []uast.Role{uast.Identifier, uast.Right}, Token: right},
+		}}
+	}
+	then := &uast.Node{InternalType: "Block", Roles: []uast.Role{uast.If, uast.Then, uast.Block}, Children: []*uast.Node{
+		assign("a", p), assign("b", "a"), assign("c", "b"),
+	}}
+	if extra {
+		then.Children = append(then.Children, ckCall("log"))
+	}
+	return &uast.Node{InternalType: "MethodDeclaration", Roles: []uast.Role{uast.Function, uast.Declaration},
+		StartPosition: &uast.Position{Line: line}, EndPosition: &uast.Position{Line: line + 5},
+		Children: []*uast.Node{
+			{InternalType: "SimpleName", Roles: []uast.Role{uast.Identifier, uast.Function, uast.Name}, Token: name},
+			{InternalType: "Block", Roles: []uast.Role{uast.Function, uast.Body, uast.Block}, Children: []*uast.Node{
+				{InternalType: "IfStatement", Roles: []uast.Role{uast.Statement, uast.If}, Children: []*uast.Node{
+					{InternalType: "InfixExpression", Roles: []uast.Role{uast.If, uast.Condition}, Properties: map[string]string{"operator": ">"}, Children: []*uast.Node{
+						{InternalType: "SimpleName", Roles: []uast.Role{uast.Identifier, uast.Left}, Token: p},
+						{InternalType: "NumberLiteral", Roles: []uast.Role{uast.Literal, uast.Right}, Properties: map[string]string{"token": limit}},
+					}},
+					{InternalType: "LineComment", Roles: []uast.Role{uast.Comment}, Token: "// " + name},
+					then,
+				}},
+			}},
+		}}
+}
+
+func cloneFiles() []*File {
+	return []*File{
+		{Path: "a.java", UAST: &uast.Node{Children: []*uast.Node{
+			cloneFunc("first", "x", "1", 1, false),
+			cloneFunc("second", "y", "2", 10, false),
+		}}},
+		{Path: "b.java", UAST: &uast.Node{Children: []*uast.Node{
+			cloneFunc("third", "z", "3", 1, true),
+		}}},
+	}
+}
+
+func TestExactClones(t *testing.T) {
+	require := require.New(t)
+
+	// the bodies of the functions are clones too, but they are already
+	// reported as part of them
+	groups := Clones{MinSize: 10}.Find(cloneFiles())
+	require.Len(groups, 1)
+	require.Equal(1.0, groups[0].Similarity)
+	require.Equal([]*Clone{
+		{File: "a.java", Function: "first", StartLine: 1, EndLine: 6, Size: 17},
+		{File: "a.java", Function: "second", StartLine: 10, EndLine: 15, Size: 17},
+	}, groups[0].Clones)
+
+	require.Len(Clones{MinSize: 18}.Find(cloneFiles()), 0)
+}
+
+func TestSimilarClones(t *testing.T) {
+	require := require.New(t)
+
+	groups := Clones{MinSize: 10, Similarity: 0.7}.Find(cloneFiles())
+	require.Len(groups, 1)
+	require.Len(groups[0].Clones, 3)
+	require.Equal("third", groups[0].Clones[0].Function)
+	require.InDelta(0.73, groups[0].Similarity, 0.01)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -23,50 +26,140 @@ var (
 type Common struct {
 	Address  string `long:"address" description:"server adress to connect to" default:"localhost:9432"`
 	Language string `long:"language" description:"language of the input" default:""`
+	UAST     bool   `long:"uast" description:"read the files as parse responses saved in JSON, such as the fixtures, instead of parsing them"`
 	Args     struct {
-		File string `positional-arg-name:"file" required:"true"`
+		Files []string `positional-arg-name:"file" required:"1"`
 	} `positional-args:"yes"`
 }
 
 func (c *Common) execute(args []string, tool tools.Tooler) error {
 	logrus.Debugf("executing command")
 
-	request, err := c.buildRequest()
+	client, err := c.dial()
 	if err != nil {
 		return err
 	}
 
-	uast, err := c.parseRequest(request)
+	for _, file := range c.Args.Files {
+		f, err := c.parseFile(client, file)
+		if err != nil {
+			return err
+		}
+
+		if err := tool.Exec(f.UAST); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Common) executeFiles(args []string, tool tools.MultiTooler) error {
+	logrus.Debugf("executing command over %d files", len(c.Args.Files))
+
+	client, err := c.dial()
 	if err != nil {
 		return err
 	}
 
-	return tool.Exec(uast)
+	var files []*tools.File
+	for _, file := range c.Args.Files {
+		f, err := c.parseFile(client, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	return tool.ExecFiles(files)
 }
 
-func (c *Common) buildRequest() (*protocol.ParseRequest, error) {
-	logrus.Debugf("reading file %s", c.Args.File)
-	content, err := ioutil.ReadFile(c.Args.File)
+// dial connects to the server, unless the files are saved parse responses
+// and the returned client is nil.
+func (c *Common) dial() (protocol.ProtocolServiceClient, error) {
+	if c.UAST {
+		return nil, nil
+	}
+	return c.connect()
+}
+
+// connect connects to the server.
+func (c *Common) connect() (protocol.ProtocolServiceClient, error) {
+	logrus.Debugf("dialing request at %s", c.Address)
+	connection, err := grpc.Dial(c.Address, grpc.WithInsecure())
 	if err != nil {
 		return nil, err
 	}
 
-	request := &protocol.ParseRequest{
-		Filename: filepath.Base(c.Args.File),
-		Language: c.Language,
-		Content:  string(content),
+	return protocol.NewProtocolServiceClient(connection), nil
+}
+
+func (c *Common) parseFile(client protocol.ProtocolServiceClient, file string) (*tools.File, error) {
+	if c.UAST {
+		return c.readFile(file)
 	}
-	return request, nil
+
+	request, err := c.buildRequest(file)
+	if err != nil {
+		return nil, err
+	}
+
+	uast, err := c.parseRequest(client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tools.File{Path: file, Language: c.Language, UAST: uast, Content: request.Content}, nil
 }
 
-func (c *Common) parseRequest(request *protocol.ParseRequest) (*uast.Node, error) {
-	logrus.Debugf("dialing request at %s", c.Address)
-	connection, err := grpc.Dial(c.Address, grpc.WithInsecure())
+// readFile reads a parse response saved in JSON. Its source code is read
+// from the same path without the .json extension, if it exists.
+func (c *Common) readFile(file string) (*tools.File, error) {
+	logrus.Debugf("reading parse response %s", file)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	response := &protocol.ParseResponse{}
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(response); err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(file, ".json")
+	var content []byte
+	if path != file {
+		content, _ = ioutil.ReadFile(path)
+	}
+	return &tools.File{Path: path, Language: c.Language, UAST: response.UAST, Content: string(content)}, nil
+}
+
+// parse parses source code with the server, e.g. a search pattern.
+func (c *Common) parse(filename, content string) (*uast.Node, error) {
+	client, err := c.connect()
 	if err != nil {
 		return nil, err
 	}
+	request := &protocol.ParseRequest{Filename: filename, Language: c.Language, Content: content}
+	return c.parseRequest(client, request)
+}
+
+func (c *Common) buildRequest(file string) (*protocol.ParseRequest, error) {
+	logrus.Debugf("reading file %s", file)
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &protocol.ParseRequest{
+		Filename: filepath.Base(file),
+		Language: c.Language,
+		Content:  string(content),
+	}
+	return request, nil
+}
 
-	client := protocol.NewProtocolServiceClient(connection)
+func (c *Common) parseRequest(client protocol.ProtocolServiceClient, request *protocol.ParseRequest) (*uast.Node, error) {
 	response, err := client.Parse(context.TODO(), request)
 	if err != nil {
 		return nil, err
@@ -13,10 +13,13 @@ func init() {
 
 func main() {
 	parser := flags.NewNamedParser("bblfsh-tools", flags.Default)
-	parser.AddCommand("dummy", "", "Run dummy tool", &Dummy{})
-	parser.AddCommand("tokenizer", "", "Run tokenizer tool", &Tokenizer{})
-	parser.AddCommand("cyclomatic", "", "Run cyclomatic complexity tool", &CyclomaticComp{})
-	parser.AddCommand("npath", "", "Run npath complexity calculation", &NPath{})
+	parser.AddCommand("list-tools", "", "List the available tools and their options", &ListTools{parser: parser})
+
+	registerPlugins()
+	if err := addRegistered(parser); err != nil {
+		logrus.Errorf("exiting with error: %s", err)
+		os.Exit(1)
+	}
 
 	if _, err := parser.Parse(); err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok {
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"reflect"
+
+	"github.com/bblfsh/tools"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// PluginsEnv is the environment variable with the directory of the plugins,
+// read before the command line is parsed to add their commands.
+const PluginsEnv = "BBLFSH_TOOLS_PLUGINS"
+
+// registerPlugins adds every plugin in the directory of PluginsEnv, if set,
+// to the registry. Plugins which can't be loaded or registered are logged
+// and skipped, so they don't break the other commands.
+func registerPlugins() {
+	dir := os.Getenv(PluginsEnv)
+	if dir == "" {
+		return
+	}
+
+	plugins, failed, err := tools.LoadPlugins(dir)
+	if err != nil {
+		logrus.Errorf("cannot load plugins of %s: %s", dir, err)
+		return
+	}
+	for _, err := range failed {
+		logrus.Errorf("skipping plugin: %s", err)
+	}
+	for _, p := range plugins {
+		if tools.LookupTool(p.Name) != nil {
+			logrus.Errorf("skipping plugin %s: there is already a tool named %s", p.Path, p.Name)
+			continue
+		}
+
+		logrus.Debugf("registering tool %s of plugin %s", p.Name, p.Path)
+		tool := p.Tool
+		info := &tools.ToolInfo{
+			Name:        p.Name,
+			Description: p.Description,
+			New:         func(*tools.ToolEnv) (tools.Tooler, error) { return tool, nil },
+		}
+		if v := reflect.ValueOf(tool); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+			info.Options = func() interface{} { return tool }
+		}
+		tools.Register(info)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bblfsh/tools"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// Registered is the command of a tool of the registry, whose options are
+// added to the Common ones.
+type Registered struct {
+	Common
+	info    *tools.ToolInfo
+	options interface{}
+}
+
+func (c *Registered) Execute(args []string) error {
+	tool, err := c.info.New(&tools.ToolEnv{
+		Options:  c.options,
+		Paths:    c.Args.Files,
+		UAST:     c.UAST,
+		Language: c.Language,
+		Parse:    c.parse,
+	})
+	if err != nil {
+		return err
+	}
+	if multi, ok := tool.(tools.MultiTooler); ok {
+		return c.executeFiles(args, multi)
+	}
+	return c.execute(args, tool)
+}
+
+// addRegistered adds a command for every tool of the registry.
+func addRegistered(parser *flags.Parser) error {
+	for _, info := range tools.Tools() {
+		if parser.Find(info.Name) != nil {
+			return fmt.Errorf("there is already a command named %s", info.Name)
+		}
+		c := &Registered{info: info}
+		cmd, err := parser.AddCommand(info.Name, "", info.Description, c)
+		if err != nil {
+			return err
+		}
+		if info.Options == nil {
+			continue
+		}
+		c.options = info.Options()
+		if _, err := cmd.AddGroup("Tool Options", "", c.options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTools lists the commands of the parser with their options, and the
+// options common to all of them.
+type ListTools struct {
+	parser *flags.Parser
+}
+
+func (c *ListTools) Execute(args []string) error {
+	common, err := flags.NewNamedParser("", flags.None).AddGroup("", "", &Common{})
+	if err != nil {
+		return err
+	}
+	isCommon := make(map[string]bool)
+	for _, opt := range common.Options() {
+		isCommon[opt.LongName] = true
+	}
+
+	commands := c.parser.Commands()
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		if cmd == c.parser.Find("list-tools") {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", cmd.Name, cmd.LongDescription)
+		options := cmd.Options()
+		for _, group := range cmd.Groups() {
+			if group.ShortDescription == "Help Options" {
+				continue
+			}
+			options = append(options, group.Options()...)
+		}
+		for _, opt := range options {
+			if !isCommon[opt.LongName] {
+				writeOption(w, opt)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nOptions of every tool:\n")
+	for _, opt := range common.Options() {
+		writeOption(w, opt)
+	}
+	return w.Flush()
+}
+
+func writeOption(w io.Writer, opt *flags.Option) {
+	var def string
+	if len(opt.Default) > 0 && strings.Join(opt.Default, "") != "" {
+		def = fmt.Sprintf(" (default: %s)", strings.Join(opt.Default, ", "))
+	}
+	fmt.Fprintf(w, "  --%s\t%s%s\n", opt.LongName, opt.Description, def)
+}
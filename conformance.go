@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrConformanceViolations = errors.NewKind("%d conformance violations found")
+	ErrInvalidContract       = errors.NewKind("invalid construct %s in contract: %s")
+)
+
+// Conformance checks the UAST of the analyzed files against a contract of
+// the child roles required by every construct, e.g. an if statement must
+// have a condition and a then branch, which the metric tools rely on, and
+// against sanity rules for the positions: a node must not end before it
+// starts, and must be inside its parent. It prints every violation followed
+// by their number per language and rule, and fails with
+// ErrConformanceViolations if any is found.
+//
+// The language of a file is found as done by Stats.
+type Conformance struct {
+	// Contract is the contract checked, DefaultContract if nil.
+	Contract *Contract
+}
+
+// ConformanceOptions are the options of Conformance.
+type ConformanceOptions struct {
+	Contract string `long:"contract" description:"YAML file with the required child roles per construct, the ones used by npath if empty"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "conformance",
+		Description: "Run driver conformance checks of roles and positions",
+		Options:     func() interface{} { return &ConformanceOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			var c Conformance
+			if path := env.Options.(*ConformanceOptions).Contract; path != "" {
+				var err error
+				if c.Contract, err = LoadContract(path); err != nil {
+					return nil, err
+				}
+			}
+			return c, nil
+		},
+	})
+}
+
+const (
+	// StartBeforeEndRule is the rule of the nodes ending before they start.
+	StartBeforeEndRule = "start-before-end"
+	// ChildInsideParentRule is the rule of the nodes starting before or
+	// ending after their parent.
+	ChildInsideParentRule = "child-inside-parent"
+)
+
+// Contract is the list of constructs whose children are checked. It is
+// usually loaded from a YAML file such as:
+//
+//   constructs:
+//     - name: if
+//       roles: [Statement, If]
+//       not: [Then, Else]
+//       children:
+//         - [If, Condition]
+//         - [If, Then]
+type Contract struct {
+	Constructs []*Construct `yaml:"constructs"`
+}
+
+// Construct is a kind of node, matched by roles, and the children it must
+// have.
+type Construct struct {
+	// Name identifies the construct, and is the rule of its violations.
+	Name string `yaml:"name"`
+	// Roles are the roles of the nodes of the construct.
+	Roles []string `yaml:"roles"`
+	// Not are the roles the nodes of the construct don't have.
+	Not []string `yaml:"not"`
+	// Children are the roles of the required children, there must be a
+	// child with every one of them.
+	Children [][]string `yaml:"children"`
+
+	roles, not []uast.Role
+	children   [][]uast.Role
+}
+
+// DefaultContract requires the child roles used by the NPath visitors.
+var DefaultContract = mustParseContract(`
+constructs:
+  - name: if
+    roles: [Statement, If]
+    not: [Then, Else]
+    children:
+      - [If, Condition]
+      - [If, Then]
+  - name: while
+    roles: [Statement, While]
+    not: [Body]
+    children:
+      - [While, Condition]
+      - [While, Body]
+  - name: do-while
+    roles: [Statement, DoWhile]
+    not: [Body]
+    children:
+      - [DoWhile, Condition]
+      - [DoWhile, Body]
+  - name: for
+    roles: [Statement, For]
+    not: [Body]
+    children:
+      - [For, Body]
+  - name: try
+    roles: [Statement, Try]
+    not: [Body, Catch, Finally]
+    children:
+      - [Try, Body]
+`)
+
+func mustParseContract(content string) *Contract {
+	contract, err := ParseContract([]byte(content))
+	if err != nil {
+		panic(err)
+	}
+	return contract
+}
+
+// LoadContract reads a contract from a YAML file.
+func LoadContract(path string) (*Contract, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseContract(content)
+}
+
+// ParseContract parses a contract in YAML.
+func ParseContract(content []byte) (*Contract, error) {
+	contract := &Contract{}
+	if err := yaml.Unmarshal(content, contract); err != nil {
+		return nil, err
+	}
+
+	for _, c := range contract.Constructs {
+		var err error
+		if len(c.Roles) == 0 {
+			return nil, ErrInvalidContract.New(c.Name, "no roles")
+		}
+		if c.roles, err = ParseRoles(c.Roles); err != nil {
+			return nil, ErrInvalidContract.New(c.Name, err)
+		}
+		if c.not, err = ParseRoles(c.Not); err != nil {
+			return nil, ErrInvalidContract.New(c.Name, err)
+		}
+		for _, names := range c.Children {
+			roles, err := ParseRoles(names)
+			if err != nil {
+				return nil, ErrInvalidContract.New(c.Name, err)
+			}
+			c.children = append(c.children, roles)
+		}
+	}
+	return contract, nil
+}
+
+func (c Conformance) Exec(n *uast.Node) error {
+	return c.ExecFiles([]*File{{UAST: n}})
+}
+
+func (c Conformance) ExecFiles(files []*File) error {
+	contract := c.Contract
+	if contract == nil {
+		contract = DefaultContract
+	}
+
+	type key struct{ language, rule string }
+	counts := make(map[key]int)
+	total := 0
+	for _, file := range files {
+		violations := contract.Check(file)
+		for _, v := range violations {
+			fmt.Print(v)
+			counts[key{fileLanguage(file), v.Rule}]++
+		}
+		total += len(violations)
+	}
+
+	keys := make([]key, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].language != keys[j].language {
+			return keys[i].language < keys[j].language
+		}
+		return keys[i].rule < keys[j].rule
+	})
+	for _, k := range keys {
+		fmt.Printf("Language:%s, Rule:%s, Violations:%d\n", k.language, k.rule, counts[k])
+	}
+
+	if total > 0 {
+		return ErrConformanceViolations.New(total)
+	}
+	return nil
+}
+
+// Check returns a finding for every node of the file breaking the contract
+// or the position rules, in preorder.
+func (c *Contract) Check(file *File) []*Finding {
+	var violations []*Finding
+	report := func(n *uast.Node, rule, message string) {
+		f := &Finding{File: file.Path, Rule: rule, Message: fmt.Sprintf("%s %s", describeNode(n), message)}
+		if pos := startPosition(n); pos != nil {
+			f.Line, f.Col = int(pos.Line), int(pos.Col)
+		}
+		violations = append(violations, f)
+	}
+
+	var visit func(n, parent *uast.Node)
+	visit = func(n, parent *uast.Node) {
+		for _, construct := range c.Constructs {
+			if !containsRoles(n, construct.roles, construct.not) {
+				continue
+			}
+			for i, roles := range construct.children {
+				if len(childrenOfRoles(n, roles, nil)) == 0 {
+					report(n, construct.Name, fmt.Sprintf("has no child with roles %s", strings.Join(construct.Children[i], ", ")))
+				}
+			}
+		}
+
+		start, end := n.StartPosition, n.EndPosition
+		if hasPosition(start) && hasPosition(end) {
+			if end.Offset < start.Offset {
+				report(n, StartBeforeEndRule, fmt.Sprintf("ends at offset %d before it starts at offset %d", end.Offset, start.Offset))
+			}
+			if parent != nil && hasPosition(parent.StartPosition) && hasPosition(parent.EndPosition) &&
+				(start.Offset < parent.StartPosition.Offset || end.Offset > parent.EndPosition.Offset) {
+				report(n, ChildInsideParentRule, fmt.Sprintf("spans offsets %d-%d out of its parent %s at %d-%d",
+					start.Offset, end.Offset, parent.InternalType, parent.StartPosition.Offset, parent.EndPosition.Offset))
+			}
+		}
+
+		for _, child := range n.Children {
+			visit(child, n)
+		}
+	}
+	visit(file.UAST, nil)
+	return violations
+}
+
+func hasPosition(pos *uast.Position) bool {
+	return pos != nil && pos.Line != 0
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestConformanceFixtures(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{"do_while", "for", "ifelse", "someFuncs", "switch", "try", "while"} {
+		file := &File{Path: name, UAST: readFixture(t, "fixtures/npath/"+name+".java.json")}
+		require.Empty(DefaultContract.Check(file), name)
+	}
+}
+
+func TestConformanceViolations(t *testing.T) {
+	require := require.New(t)
+
+	pos := func(offset, line, col uint32) *uast.Position {
+		return &uast.Position{Offset: offset, Line: line, Col: col}
+	}
+	file := &File{Path: "a.x", UAST: &uast.Node{InternalType: "Block", Children: []*uast.Node{
+		{InternalType: "If", Roles: []uast.Role{uast.Statement, uast.If}, StartPosition: pos(0, 1, 1), EndPosition: pos(20, 2, 5), Children: []*uast.Node{
+			{InternalType: "Cond", Roles: []uast.Role{uast.If, uast.Condition}, StartPosition: pos(4, 1, 5), EndPosition: pos(2, 1, 3)},
+			{InternalType: "Then", Roles: []uast.Role{uast.Statement, uast.If, uast.Then}, StartPosition: pos(10, 1, 11), EndPosition: pos(25, 3, 1)},
+		}},
+		{InternalType: "While", Roles: []uast.Role{uast.Statement, uast.While}, Children: []*uast.Node{
+			{InternalType: "Body", Roles: []uast.Role{uast.Statement, uast.While, uast.Body}},
+		}},
+	}}}
+
+	require.Equal([]*Finding{{
+		File: "a.x", Line: 1, Col: 5, Rule: StartBeforeEndRule,
+		Message: "Cond [If, Condition] ends at offset 2 before it starts at offset 4",
+	}, {
+		File: "a.x", Line: 1, Col: 11, Rule: ChildInsideParentRule,
+		Message: "Then [Statement, If, Then] spans offsets 10-25 out of its parent If at 0-20",
+	}, {
+		File: "a.x", Rule: "while",
+		Message: "While [Statement, While] (1 children) has no child with roles While, Condition",
+	}}, DefaultContract.Check(file))
+
+	require.True(ErrConformanceViolations.Is(Conformance{}.ExecFiles([]*File{file})))
+}
+
+func TestParseContract(t *testing.T) {
+	require := require.New(t)
+
+	contract, err := ParseContract([]byte(`
+constructs:
+  - name: call
+    roles: [Call]
+    not: [Callee]
+    children:
+      - [Call, Callee]
+`))
+	require.NoError(err)
+	file := &File{Path: "a.x", UAST: &uast.Node{InternalType: "Call", Roles: []uast.Role{uast.Call}}}
+	require.Len(contract.Check(file), 1)
+	require.Equal("call", contract.Check(file)[0].Rule)
+
+	_, err = ParseContract([]byte("constructs:\n  - name: bad\n    roles: [Nope]\n"))
+	require.True(ErrInvalidContract.Is(err))
+	_, err = ParseContract([]byte("constructs:\n  - name: empty\n"))
+	require.True(ErrInvalidContract.Is(err))
+}
@@ -48,6 +48,14 @@ import (
 
 type CyclomaticComplexity struct{}
 
+func init() {
+	Register(&ToolInfo{
+		Name:        "cyclomatic",
+		Description: "Run cyclomatic complexity tool",
+		New:         func(*ToolEnv) (Tooler, error) { return CyclomaticComplexity{}, nil },
+	})
+}
+
 func (cc CyclomaticComplexity) Exec(n *uast.Node) error {
 	result := cyclomaticComplexity(n)
 	fmt.Println("Cyclomatic Complexity = ", result)
@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Deps extracts the dependency graph between the packages of the analyzed
+// files from their imports, lists its cycles and computes the afferent
+// coupling, efferent coupling and instability of every package as defined
+// by Robert C. Martin: https://en.wikipedia.org/wiki/Software_package_metrics
+//
+// The package of a file is the one it declares (a node with the Package and
+// Declaration roles) or, for languages without package declarations, the
+// file path without extension. Go packages are imported by directory, so
+// the package of a Go file is its directory. The imported path is read from
+// the nodes with the Import and Pathname roles, so aliases don't change the
+// dependency.
+//
+// Imports are matched to the analyzed packages comparing their components,
+// split on dots and slashes: an import matches the package with the same
+// components or, if it is dotted, the one without its last component (e.g.
+// the Java class `a.b.C` in package `a.b`). Imports of paths only match
+// the same directory, so an unanalyzed subpackage is an external package
+// rather than its parent. Relative imports (`./util`) are resolved against
+// the directory of the importing file, and imports under Module against the
+// root of the project, e.g. `github.com/bblfsh/tools/cmd` is the package `cmd`. Imports
+// matching no analyzed package are external packages, which count as
+// efferent dependencies.
+type Deps struct {
+	// Format is the output format: text, dot or json.
+	Format string
+	// Module is the import path of the root of the project, such as the
+	// module path of Go projects, with the paths of the files relative to it.
+	Module string
+}
+
+// DepsOptions are the options of Deps.
+type DepsOptions struct {
+	Format string `long:"format" description:"output format: text, dot or json" default:"text"`
+	Module string `long:"module" description:"import path of the root of the project, such as the module path of Go projects"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "deps",
+		Description: "Run package dependency graph extraction",
+		Options:     func() interface{} { return &DepsOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*DepsOptions)
+			return Deps{Format: o.Format, Module: o.Module}, nil
+		},
+	})
+}
+
+type DepsData struct {
+	Packages     []*DepsPackage `json:"packages"`
+	Dependencies []*Dependency  `json:"dependencies"`
+	// Cycles are the strongly connected components of the graph with more
+	// than one package, plus the packages importing themselves.
+	Cycles [][]string `json:"cycles"`
+}
+
+type DepsPackage struct {
+	Name string `json:"name"`
+	// Files are the analyzed files in the package, empty for external ones.
+	Files    []string `json:"files,omitempty"`
+	External bool     `json:"external"`
+	// Afferent is the number of packages depending on this one.
+	Afferent int `json:"afferent"`
+	// Efferent is the number of packages this one depends on.
+	Efferent int `json:"efferent"`
+	// Instability is Efferent / (Afferent + Efferent), zero for packages
+	// without dependencies.
+	Instability float64 `json:"instability"`
+}
+
+type Dependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Count is the number of imports from one package to the other.
+	Count int `json:"count"`
+}
+
+// Import is an import found in a file.
+type Import struct {
+	// Path is the imported path as written, without quotes.
+	Path string
+	// Line is the line of the import, zero if unknown.
+	Line int
+}
+
+func (d Deps) Exec(n *uast.Node) error {
+	return d.ExecFiles([]*File{{UAST: n}})
+}
+
+func (d Deps) ExecFiles(files []*File) error {
+	data := DepsOf(files, d.Module)
+	switch d.Format {
+	case "", "text":
+		for _, p := range data.Packages {
+			if !p.External {
+				fmt.Print(p)
+			}
+		}
+		for _, cycle := range data.Cycles {
+			fmt.Printf("Cycle:%s\n", strings.Join(cycle, ", "))
+		}
+		return nil
+	case "dot":
+		return data.WriteDOT(os.Stdout)
+	case "json":
+		return data.WriteJSON(os.Stdout)
+	default:
+		return ErrUnknownFormat.New(d.Format)
+	}
+}
+
+func (dp *DepsPackage) String() string {
+	return fmt.Sprintf("Package:%s, Ca:%d, Ce:%d, Instability:%.2f\n",
+		dp.Name, dp.Afferent, dp.Efferent, dp.Instability)
+}
+
+// WriteDOT writes the dependency graph in Graphviz DOT format. External
+// packages are dashed and the dependencies in a cycle red.
+func (dd *DepsData) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph deps {"); err != nil {
+		return err
+	}
+	for _, p := range dd.Packages {
+		attrs := ""
+		if p.External {
+			attrs = " [style=dashed]"
+		}
+		if _, err := fmt.Fprintf(w, "\t%s%s;\n", dotQuote(p.Name), attrs); err != nil {
+			return err
+		}
+	}
+
+	inCycle := make(map[string]int)
+	for i, cycle := range dd.Cycles {
+		for _, name := range cycle {
+			inCycle[name] = i + 1
+		}
+	}
+	for _, dep := range dd.Dependencies {
+		attrs := ""
+		if c := inCycle[dep.From]; c != 0 && c == inCycle[dep.To] {
+			attrs = " [color=red]"
+		}
+		if _, err := fmt.Fprintf(w, "\t%s -> %s%s;\n", dotQuote(dep.From), dotQuote(dep.To), attrs); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// WriteJSON writes the dependency graph as a JSON document.
+func (dd *DepsData) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dd)
+}
+
+// DepsOf extracts the dependency graph between the packages of the files,
+// module being the import path of the root of the project if known.
+func DepsOf(files []*File, module string) *DepsData {
+	data := &DepsData{}
+	packages := make(map[string]*DepsPackage)
+	filePackages := make([]string, len(files))
+	for i, file := range files {
+		name := PackageOf(file)
+		filePackages[i] = name
+		p, ok := packages[name]
+		if !ok {
+			p = &DepsPackage{Name: name}
+			packages[name] = p
+			data.Packages = append(data.Packages, p)
+		}
+		p.Files = append(p.Files, file.Path)
+	}
+
+	var internal []string
+	for _, p := range data.Packages {
+		internal = append(internal, p.Name)
+	}
+
+	deps := make(map[[2]string]*Dependency)
+	for i, file := range files {
+		from := filePackages[i]
+		for _, imp := range Imports(file.UAST) {
+			to := resolveImport(internal, module, file.Path, imp.Path)
+			if _, ok := packages[to]; !ok {
+				packages[to] = &DepsPackage{Name: to, External: true}
+				data.Packages = append(data.Packages, packages[to])
+			}
+
+			key := [2]string{from, to}
+			if dep, ok := deps[key]; ok {
+				dep.Count++
+				continue
+			}
+			deps[key] = &Dependency{From: from, To: to, Count: 1}
+			data.Dependencies = append(data.Dependencies, deps[key])
+			if from != to {
+				packages[from].Efferent++
+				packages[to].Afferent++
+			}
+		}
+	}
+
+	for _, p := range data.Packages {
+		if total := p.Afferent + p.Efferent; total > 0 {
+			p.Instability = float64(p.Efferent) / float64(total)
+		}
+	}
+
+	data.Cycles = dependencyCycles(data.Packages, data.Dependencies)
+	return data
+}
+
+// PackageOf returns the package a file belongs to: its directory for Go,
+// the package it declares or, if none, its path without extension.
+func PackageOf(file *File) string {
+	if fileLanguage(file) == "go" {
+		return path.Dir(file.Path)
+	}
+	decls := deepChildrenOfRoles(file.UAST, []uast.Role{uast.Package, uast.Declaration}, []uast.Role{uast.Visibility, uast.Type, uast.Import})
+	if len(decls) > 0 {
+		if name := qualifiedName(decls[0]); name != "" {
+			return name
+		}
+	}
+	return strings.TrimSuffix(file.Path, path.Ext(file.Path))
+}
+
+// Imports returns the imports found in the node.
+func Imports(n *uast.Node) []*Import {
+	var imports []*Import
+	for _, child := range n.Children {
+		if !containsRoles(child, []uast.Role{uast.Import, uast.Pathname}, nil) {
+			imports = append(imports, Imports(child)...)
+			continue
+		}
+
+		imp := &Import{Path: strings.Trim(qualifiedName(child), "\"'`<>")}
+		start, _ := lineRange(child)
+		imp.Line = int(start)
+		if imp.Path != "" {
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+// qualifiedName returns the token of the node or, if it has none, the
+// tokens under it joined with dots.
+func qualifiedName(n *uast.Node) string {
+	if n.Token != "" {
+		return n.Token
+	}
+	var parts []string
+	for _, child := range n.Children {
+		if name := qualifiedName(child); name != "" {
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// resolveImport returns the package among the given ones an import refers
+// to, or the import path itself if it refers to none of them. Imports under
+// module, if not empty, are relative to the root of the project.
+func resolveImport(packages []string, module, file, imp string) string {
+	target := imp
+	switch {
+	case strings.HasPrefix(imp, "./") || strings.HasPrefix(imp, "../"):
+		target = path.Join(path.Dir(file), imp)
+	case module != "" && (imp == module || strings.HasPrefix(imp, module+"/")):
+		target = path.Join(".", strings.TrimPrefix(imp, module))
+	}
+
+	candidates := [][]string{pathComponents(target)}
+	// a dotted import may name a member of a package, e.g. the Java class
+	// a.b.C, while paths name the package itself
+	if !strings.ContainsAny(imp, "/\\") {
+		if i := strings.LastIndex(imp, "."); i > 0 {
+			candidates = append(candidates, pathComponents(imp[:i]))
+		}
+	}
+	for _, parts := range candidates {
+		for _, p := range packages {
+			if componentsEqual(pathComponents(p), parts) {
+				return p
+			}
+		}
+	}
+	return imp
+}
+
+func pathComponents(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '.' || r == '/' || r == '\\'
+	})
+}
+
+func componentsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyCycles returns the cycles of the graph using Tarjan's strongly
+// connected components algorithm. The packages of every cycle are sorted.
+func dependencyCycles(packages []*DepsPackage, deps []*Dependency) [][]string {
+	edges := make(map[string][]string)
+	selfLoops := make(map[string]bool)
+	for _, dep := range deps {
+		if dep.From == dep.To {
+			selfLoops[dep.From] = true
+			continue
+		}
+		edges[dep.From] = append(edges[dep.From], dep.To)
+	}
+
+	var (
+		cycles  [][]string
+		stack   []string
+		counter int
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		connect func(string)
+	)
+	connect = func(v string) {
+		counter++
+		index[v], lowlink[v] = counter, counter
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if index[w] == 0 {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] != index[v] {
+			return
+		}
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoops[v] {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, p := range packages {
+		if index[p.Name] == 0 {
+			connect(p.Name)
+		}
+	}
+	return cycles
+}
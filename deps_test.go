@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func depsQualified(roles []uast.Role, name string) *uast.Node {
+	n := &uast.Node{InternalType: "QualifiedName", Roles: roles}
+	for _, part := range strings.Split(name, ".") {
+		n.Children = append(n.Children, &uast.Node{InternalType: "SimpleName", Roles: []uast.Role{uast.Identifier}, Token: part})
+	}
+	return n
+}
+
+func depsPackage(name string) *uast.Node {
+	return &uast.Node{InternalType: "PackageDeclaration", Roles: []uast.Role{uast.Package, uast.Declaration}, Children: []*uast.Node{
+		depsQualified([]uast.Role{uast.Identifier, uast.Qualified}, name),
+	}}
+}
+
+func depsImport(name string, line uint32) *uast.Node {
+	path := depsQualified([]uast.Role{uast.Import, uast.Pathname, uast.Qualified}, name)
+	path.StartPosition = &uast.Position{Line: line}
+	return &uast.Node{InternalType: "ImportDeclaration", Roles: []uast.Role{uast.Import, uast.Declaration}, Children: []*uast.Node{path}}
+}
+
+func depsGoFile(path, pkg string, imports ...string) *File {
+	n := &uast.Node{Children: []*uast.Node{depsPackage(pkg)}}
+	for _, imp := range imports {
+		n.Children = append(n.Children, &uast.Node{InternalType: "ImportSpec", Roles: []uast.Role{uast.Import, uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "BasicLit", Roles: []uast.Role{uast.Import, uast.Pathname, uast.Literal}, Token: `"` + imp + `"`},
+		}})
+	}
+	return &File{Path: path, UAST: n}
+}
+
+func depsFiles() []*File {
+	return []*File{
+		{Path: "a.java", UAST: &uast.Node{Children: []*uast.Node{
+			depsPackage("app.domain"),
+			depsImport("app.infra.Db", 3),
+			depsImport("java.util.List", 4),
+			{InternalType: "TypeDeclaration", Roles: []uast.Role{uast.Visibility, uast.Package, uast.Declaration, uast.Type}},
+		}}},
+		{Path: "b.java", UAST: &uast.Node{Children: []*uast.Node{
+			depsPackage("app.infra"),
+			depsImport("app.domain.User", 3),
+		}}},
+		{Path: "web/main.js", UAST: &uast.Node{Children: []*uast.Node{
+			{InternalType: "ImportDeclaration", Roles: []uast.Role{uast.Import, uast.Declaration}, Children: []*uast.Node{
+				{InternalType: "Alias", Roles: []uast.Role{uast.Import, uast.Alias, uast.Identifier}, Token: "u"},
+				{InternalType: "StringLiteral", Roles: []uast.Role{uast.Import, uast.Pathname, uast.Literal}, Token: "'./util'"},
+			}},
+		}}},
+		{Path: "web/util.js", UAST: &uast.Node{}},
+	}
+}
+
+func TestImports(t *testing.T) {
+	require := require.New(t)
+
+	files := depsFiles()
+	require.Equal([]*Import{{Path: "app.infra.Db", Line: 3}, {Path: "java.util.List", Line: 4}}, Imports(files[0].UAST))
+	require.Equal([]*Import{{Path: "./util"}}, Imports(files[2].UAST))
+
+	require.Equal("app.domain", PackageOf(files[0]))
+	require.Equal("web/util", PackageOf(files[3]))
+}
+
+func TestDeps(t *testing.T) {
+	require := require.New(t)
+
+	data := DepsOf(depsFiles(), "")
+	require.Equal([]*DepsPackage{
+		{Name: "app.domain", Files: []string{"a.java"}, Afferent: 1, Efferent: 2, Instability: 2.0 / 3.0},
+		{Name: "app.infra", Files: []string{"b.java"}, Afferent: 1, Efferent: 1, Instability: 0.5},
+		{Name: "web/main", Files: []string{"web/main.js"}, Afferent: 0, Efferent: 1, Instability: 1},
+		{Name: "web/util", Files: []string{"web/util.js"}, Afferent: 1, Efferent: 0, Instability: 0},
+		{Name: "java.util.List", External: true, Afferent: 1},
+	}, data.Packages)
+	require.Equal([][]string{{"app.domain", "app.infra"}}, data.Cycles)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(data.WriteDOT(buf))
+	require.Contains(buf.String(), "\t\"app.infra\" -> \"app.domain\" [color=red];\n")
+	require.Contains(buf.String(), "\t\"web/main\" -> \"web/util\";\n")
+	require.Contains(buf.String(), "\t\"java.util.List\" [style=dashed];\n")
+}
+
+func TestResolveImport(t *testing.T) {
+	require := require.New(t)
+
+	packages := []string{".", "cmd", "app", "app/models"}
+	require.Equal(".", resolveImport(packages, "github.com/bblfsh/tools", "main.go", "github.com/bblfsh/tools"))
+	require.Equal("cmd", resolveImport(packages, "github.com/bblfsh/tools", "main.go", "github.com/bblfsh/tools/cmd"))
+	require.Equal("github.com/bblfsh/tools/cmd", resolveImport(packages, "", "main.go", "github.com/bblfsh/tools/cmd"))
+	require.Equal("github.com/bblfsh/tools/cmd/sub", resolveImport(packages, "github.com/bblfsh/tools", "main.go", "github.com/bblfsh/tools/cmd/sub"))
+	require.Equal("app/models", resolveImport(packages, "", "main.py", "app.models.user"))
+	require.Equal("app.models.user.name", resolveImport(packages, "", "main.py", "app.models.user.name"))
+	require.Equal("./app/models/user", resolveImport(packages, "", "main.js", "./app/models/user"))
+	require.Equal("app", resolveImport(packages, "", "app/models/user.js", "../../app"))
+	require.Equal("fmt", resolveImport(packages, "", "main.go", "fmt"))
+	require.Equal("github.com/other/app", resolveImport(packages, "", "main.go", "github.com/other/app"))
+}
+
+func TestDepsLocalPackageNamedAsStdlib(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{
+		depsGoFile("main.go", "main", "errors", "log", "github.com/acme/proj/internal/errors"),
+		depsGoFile("internal/errors/errors.go", "errors", "errors"),
+		depsGoFile("pkg/log/log.go", "log", "log", "github.com/acme/proj/internal/errors"),
+	}
+
+	data := DepsOf(files, "github.com/acme/proj")
+	require.Equal([]*DepsPackage{
+		{Name: ".", Files: []string{"main.go"}, Efferent: 3, Instability: 1},
+		{Name: "internal/errors", Files: []string{"internal/errors/errors.go"}, Afferent: 2, Efferent: 1, Instability: 1.0 / 3.0},
+		{Name: "pkg/log", Files: []string{"pkg/log/log.go"}, Efferent: 2, Instability: 1},
+		{Name: "errors", External: true, Afferent: 2},
+		{Name: "log", External: true, Afferent: 2},
+	}, data.Packages)
+	require.Empty(data.Cycles)
+}
+
+func TestDepsUnanalyzedSubpackage(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{
+		depsGoFile("a/a.go", "a", "github.com/acme/proj/b"),
+		depsGoFile("b/b.go", "b", "github.com/acme/proj/a/internal"),
+	}
+
+	data := DepsOf(files, "github.com/acme/proj")
+	require.Equal([]*DepsPackage{
+		{Name: "a", Files: []string{"a/a.go"}, Afferent: 0, Efferent: 1, Instability: 1},
+		{Name: "b", Files: []string{"b/b.go"}, Afferent: 1, Efferent: 1, Instability: 0.5},
+		{Name: "github.com/acme/proj/a/internal", External: true, Afferent: 1},
+	}, data.Packages)
+	require.Empty(data.Cycles)
+}
@@ -4,6 +4,14 @@ import "gopkg.in/bblfsh/sdk.v1/uast"
 
 type Dummy struct{}
 
+func init() {
+	Register(&ToolInfo{
+		Name:        "dummy",
+		Description: "Run dummy tool",
+		New:         func(*ToolEnv) (Tooler, error) { return Dummy{}, nil },
+	})
+}
+
 func (d Dummy) Exec(*uast.Node) error {
 	println("It works! You can now proceed with another tool :)")
 	return nil
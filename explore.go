@@ -0,0 +1,302 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Explore opens an interactive session to navigate the UAST of every
+// analyzed file, one after the other, reading commands from In and writing
+// to Out, the standard input and output if nil. Type help to list the
+// commands.
+type Explore struct {
+	In  io.Reader
+	Out io.Writer
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "explore",
+		Description: "Run interactive UAST explorer",
+		New:         func(*ToolEnv) (Tooler, error) { return Explore{}, nil },
+	})
+}
+
+const exploreHelp = `Commands:
+  ls                 list the children of the current node
+  cd <n>|..|/        go to the n-th child, the parent or the root
+  up                 go to the parent
+  pwd                print the path to the current node
+  info               print the roles, internal type, token, properties and
+                     positions of the current node
+  src                print the source code lines of the current node
+  find <role>...     list the descendants with all the given roles
+  query <query>      run a query over the current node as the root, see
+                     tools.Query
+  goto <n>           go to the n-th result of the last find or query
+  next               go to the next file
+  quit               end the session
+`
+
+func (e Explore) Exec(n *uast.Node) error {
+	return e.ExecFiles([]*File{{UAST: n}})
+}
+
+func (e Explore) ExecFiles(files []*File) error {
+	in, out := e.In, e.Out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	scanner := bufio.NewScanner(in)
+	for _, file := range files {
+		session := &exploreSession{file: file, path: []*uast.Node{file.UAST}, out: out}
+		fmt.Fprintf(out, "File:%s, type help to list the commands\n", file.Path)
+		for {
+			fmt.Fprintf(out, "%s> ", session.pwd())
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return scanner.Err()
+			}
+			done, quit := session.run(scanner.Text())
+			if quit {
+				return nil
+			}
+			if done {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+type exploreSession struct {
+	file *File
+	// path are the nodes from the root to the current one.
+	path []*uast.Node
+	// results are the nodes found by the last find or query, along with
+	// their paths.
+	results [][]*uast.Node
+	out     io.Writer
+}
+
+func (s *exploreSession) current() *uast.Node {
+	return s.path[len(s.path)-1]
+}
+
+// run runs a command line, and returns whether the session with the file is
+// done, and whether the whole exploration is.
+func (s *exploreSession) run(line string) (done, quit bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, false
+	}
+	command, args := fields[0], fields[1:]
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), command))
+
+	switch command {
+	case "help", "?":
+		fmt.Fprint(s.out, exploreHelp)
+	case "ls":
+		s.ls()
+	case "cd":
+		s.cd(args)
+	case "up":
+		s.cd([]string{".."})
+	case "pwd":
+		fmt.Fprintln(s.out, s.pwd())
+	case "info":
+		s.info()
+	case "src":
+		s.src()
+	case "find":
+		s.find(args)
+	case "query":
+		s.query(rest)
+	case "goto":
+		s.goTo(args)
+	case "next":
+		return true, false
+	case "quit", "exit":
+		return true, true
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, type help to list the commands\n", command)
+	}
+	return false, false
+}
+
+// pwd returns the path to the current node as the indices of the nodes in
+// their parents and the internal type of the current node.
+func (s *exploreSession) pwd() string {
+	var path strings.Builder
+	for i := 1; i < len(s.path); i++ {
+		for j, child := range s.path[i-1].Children {
+			if child == s.path[i] {
+				fmt.Fprintf(&path, "/%d", j)
+				break
+			}
+		}
+	}
+	if path.Len() == 0 {
+		path.WriteString("/")
+	}
+	return path.String() + ":" + s.current().InternalType
+}
+
+func (s *exploreSession) ls() {
+	for i, child := range s.current().Children {
+		fmt.Fprintf(s.out, "%d\t%s\n", i, describeNode(child))
+	}
+}
+
+func describeNode(n *uast.Node) string {
+	desc := fmt.Sprintf("%s [%s]", n.InternalType, strings.Join(roleNames(n.Roles), ", "))
+	if n.Token != "" {
+		desc += " " + strconv.Quote(n.Token)
+	}
+	if len(n.Children) > 0 {
+		desc += fmt.Sprintf(" (%d children)", len(n.Children))
+	}
+	return desc
+}
+
+func (s *exploreSession) cd(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(s.out, "usage: cd <n>|..|/")
+		return
+	}
+	switch args[0] {
+	case "/":
+		s.path = s.path[:1]
+	case "..":
+		if len(s.path) > 1 {
+			s.path = s.path[:len(s.path)-1]
+		}
+	default:
+		i, err := strconv.Atoi(args[0])
+		if err != nil || i < 0 || i >= len(s.current().Children) {
+			fmt.Fprintf(s.out, "no child %s\n", args[0])
+			return
+		}
+		s.path = append(s.path, s.current().Children[i])
+	}
+}
+
+func (s *exploreSession) info() {
+	n := s.current()
+	fmt.Fprintf(s.out, "InternalType: %s\n", n.InternalType)
+	fmt.Fprintf(s.out, "Roles: %s\n", strings.Join(roleNames(n.Roles), ", "))
+	fmt.Fprintf(s.out, "Token: %q\n", n.Token)
+	keys := make([]string, 0, len(n.Properties))
+	for k := range n.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(s.out, "Property %s: %q\n", k, n.Properties[k])
+	}
+	for _, pos := range []struct {
+		name string
+		pos  *uast.Position
+	}{{"StartPosition", n.StartPosition}, {"EndPosition", n.EndPosition}} {
+		if pos.pos != nil {
+			fmt.Fprintf(s.out, "%s: line %d, col %d, offset %d\n", pos.name, pos.pos.Line, pos.pos.Col, pos.pos.Offset)
+		}
+	}
+	fmt.Fprintf(s.out, "Children: %d\n", len(n.Children))
+}
+
+// src prints the lines of source code spanned by the current node, as
+// positions aren't set on every node to slice its exact code.
+func (s *exploreSession) src() {
+	start, end := lineRange(s.current())
+	if start == 0 || s.file.Content == "" {
+		fmt.Fprintln(s.out, "no source code available for this node")
+		return
+	}
+	lines := strings.Split(s.file.Content, "\n")
+	for line := start; line <= end && int(line) <= len(lines); line++ {
+		fmt.Fprintf(s.out, "%4d | %s\n", line, strings.TrimRight(lines[line-1], "\r"))
+	}
+}
+
+func (s *exploreSession) find(args []string) {
+	roles, err := ParseRoles(args)
+	if err != nil || len(roles) == 0 {
+		fmt.Fprintln(s.out, "usage: find <role>...")
+		return
+	}
+	s.setResults(s.path, func(n *uast.Node) bool {
+		return containsRoles(n, roles, nil)
+	})
+}
+
+func (s *exploreSession) query(text string) {
+	q, err := ParseQuery(text)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	result := q.Eval(s.current())
+	nodes, ok := result.([]*uast.Node)
+	if !ok {
+		fmt.Fprintln(s.out, result)
+		return
+	}
+	selected := make(map[*uast.Node]bool, len(nodes))
+	for _, n := range nodes {
+		selected[n] = true
+	}
+	s.setResults(s.path, func(n *uast.Node) bool { return selected[n] })
+}
+
+// setResults keeps the last node of path and its descendants matching fn as
+// the results, and prints them.
+func (s *exploreSession) setResults(path []*uast.Node, fn func(*uast.Node) bool) {
+	s.results = nil
+	var visit func(path []*uast.Node)
+	visit = func(path []*uast.Node) {
+		n := path[len(path)-1]
+		if fn(n) {
+			s.results = append(s.results, path)
+		}
+		for _, child := range n.Children {
+			visit(append(path[:len(path):len(path)], child))
+		}
+	}
+	visit(path)
+
+	for i, path := range s.results {
+		n := path[len(path)-1]
+		line := ""
+		if pos := startPosition(n); pos != nil {
+			line = fmt.Sprintf(" at line %d", pos.Line)
+		}
+		fmt.Fprintf(s.out, "%d\t%s%s\n", i, describeNode(n), line)
+	}
+	if len(s.results) == 0 {
+		fmt.Fprintln(s.out, "no results")
+	}
+}
+
+func (s *exploreSession) goTo(args []string) {
+	i := -1
+	if len(args) == 1 {
+		i, _ = strconv.Atoi(args[0])
+	}
+	if i < 0 || i >= len(s.results) {
+		fmt.Fprintln(s.out, "usage: goto <n>, with n the index of a result")
+		return
+	}
+	s.path = s.results[i]
+}
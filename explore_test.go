@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplore(t *testing.T) {
+	require := require.New(t)
+
+	content, err := ioutil.ReadFile("fixtures/npath/ifelse.java")
+	require.NoError(err)
+	file := &File{
+		Path:    "fixtures/npath/ifelse.java",
+		UAST:    readFixture(t, "fixtures/npath/ifelse.java.json"),
+		Content: string(content),
+	}
+
+	commands := []string{
+		"ls",
+		"cd 0",
+		"cd 5",
+		"find If Statement",
+		"goto 0",
+		"info",
+		"src",
+		"query count(.//SimpleName)",
+		"query //MethodInvocation",
+		"goto 1",
+		"pwd",
+		"up",
+		"cd /",
+		"nope",
+		"quit",
+		"ls",
+	}
+	var out bytes.Buffer
+	err = Explore{In: strings.NewReader(strings.Join(commands, "\n")), Out: &out}.ExecFiles([]*File{file})
+	require.NoError(err)
+
+	output := out.String()
+	for _, expected := range []string{
+		"File:fixtures/npath/ifelse.java, type help to list the commands\n",
+		"/:CompilationUnit> 0\tTypeDeclaration [Visibility, Package, Declaration, Type] (2 children)\n",
+		"/0:TypeDeclaration> no child 5\n",
+		"0\tIfStatement [Statement, If] \"if\" (3 children) at line 3\n",
+		"2\tBlock [If, Else, Body, Statement, Block, Scope] (1 children) at line 6\n",
+		"InternalType: IfStatement\nRoles: Statement, If\nToken: \"if\"\nProperty internalRole: \"statements\"\nChildren: 3\n",
+		"   3 | \t    if (true) {\n   4 |             System.out.println(true);\n",
+		"   6 | \t        System.out.println(false);\n",
+		"/0/1/2/0:IfStatement> 6\n",
+		"1\tMethodInvocation [Expression, Call] (3 children) at line 6\n",
+		"/0/1/2/0/2/0/0:MethodInvocation\n",
+		"/0/1/2/0/2/0:ExpressionStatement> /:CompilationUnit> unknown command nope",
+	} {
+		require.Contains(output, expected)
+	}
+	require.Equal(1, strings.Count(output, "0\tTypeDeclaration"), "commands after quit must not run")
+}
+
+func TestExploreNoSource(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	err := Explore{In: strings.NewReader("src\n"), Out: &out}.Exec(readFixture(t, "fixtures/npath/ifelse.java.json"))
+	require.NoError(err)
+	require.Contains(out.String(), "no source code available for this node\n")
+}
@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var ErrExternal = errors.NewKind("external tool %s failed on %s: %s")
+
+// External encodings of the UAST in the requests.
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// External runs an external executable, written in any language, over the
+// UAST of every analyzed file and prints the metrics and findings it
+// returns, as Script does.
+//
+// The executable is run once per file. It reads an ExternalRequest in JSON
+// from its standard input, and writes an ExternalResponse in JSON to its
+// standard output, e.g.:
+//
+//   {"file": {"path": "A.java", "language": "java", "content": "..."},
+//    "uast": {"InternalType": "CompilationUnit", "Children": [...]}}
+//
+//   {"metrics": [{"name": "depth", "value": 7}],
+//    "findings": [{"line": 3, "rule": "todo", "message": "TODO comment"}]}
+//
+// The file of the metrics and findings may be left empty. Its standard
+// error goes to the standard error, and it fails if it exits with a
+// non-zero status, if it returns an error or if it runs out of time.
+type External struct {
+	// Command is the path of the executable.
+	Command string
+	// Args are the arguments of the executable.
+	Args []string
+	// Encoding is the encoding of the UAST: json or protobuf.
+	Encoding string
+	// Timeout is the time the executable can run per file, none if zero.
+	Timeout time.Duration
+	// Format is the output format: text or json.
+	Format string
+}
+
+// ExternalOptions are the options of External.
+type ExternalOptions struct {
+	Command  string        `long:"command" description:"external executable run over every file" required:"true"`
+	Args     []string      `long:"arg" description:"argument of the executable, may be repeated"`
+	Encoding string        `long:"encoding" description:"encoding of the UAST given to the executable: json or protobuf" default:"json"`
+	Timeout  time.Duration `long:"timeout" description:"time the executable can run per file, none if zero" default:"1m"`
+	Format   string        `long:"format" description:"output format: text or json" default:"text"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "external",
+		Description: "Run external executable computing metrics and findings",
+		Options:     func() interface{} { return &ExternalOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*ExternalOptions)
+			return External{Command: o.Command, Args: o.Args, Encoding: o.Encoding, Timeout: o.Timeout, Format: o.Format}, nil
+		},
+	})
+}
+
+// ExternalRequest is the request read by an external tool.
+type ExternalRequest struct {
+	File ExternalFile `json:"file"`
+	// UAST is the root node of the file, with the json encoding.
+	UAST *uast.Node `json:"uast,omitempty"`
+	// UASTProtobuf is the root node of the file encoded as the Node message
+	// of the protocol of the server, with the protobuf encoding. It is in
+	// base64, as every byte array in JSON.
+	UASTProtobuf []byte `json:"uast_protobuf,omitempty"`
+}
+
+// ExternalFile is the metadata of a file given to an external tool.
+type ExternalFile struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Content  string `json:"content,omitempty"`
+}
+
+// ExternalResponse is the response written by an external tool.
+type ExternalResponse struct {
+	Results
+	// Error, if not empty, is the reason the tool failed.
+	Error string `json:"error,omitempty"`
+}
+
+func (e External) Exec(n *uast.Node) error {
+	return e.ExecFiles([]*File{{UAST: n}})
+}
+
+func (e External) ExecFiles(files []*File) error {
+	results := &Results{}
+	for _, file := range files {
+		if err := e.Run(file, results); err != nil {
+			return err
+		}
+	}
+	return results.Write(os.Stdout, e.Format)
+}
+
+// Run runs the executable over the file, adding the metrics and findings
+// it returns to results.
+func (e External) Run(file *File, results *Results) error {
+	request, err := e.request(file)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(e.Command, e.Args...)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	// the tool may be a wrapper whose children inherit its standard output,
+	// so on timeout its whole process group is killed
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return ErrExternal.New(e.Command, file.Path, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if e.Timeout > 0 {
+		timer := time.NewTimer(e.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return ErrExternal.New(e.Command, file.Path, err)
+		}
+	case <-timeout:
+		killProcessGroup(cmd)
+		<-done
+		return ErrExternal.New(e.Command, file.Path, fmt.Sprintf("timed out after %s", e.Timeout))
+	}
+
+	response := &ExternalResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return ErrExternal.New(e.Command, file.Path, fmt.Sprintf("invalid response: %s", err))
+	}
+	if response.Error != "" {
+		return ErrExternal.New(e.Command, file.Path, response.Error)
+	}
+
+	for _, m := range response.Metrics {
+		if m.File == "" {
+			m.File = file.Path
+		}
+		results.Metrics = append(results.Metrics, m)
+	}
+	for _, f := range response.Findings {
+		if f.File == "" {
+			f.File = file.Path
+		}
+		results.Findings = append(results.Findings, f)
+	}
+	return nil
+}
+
+// request returns the request of the file in JSON.
+func (e External) request(file *File) ([]byte, error) {
+	request := &ExternalRequest{File: ExternalFile{
+		Path:     file.Path,
+		Language: fileLanguage(file),
+		Content:  file.Content,
+	}}
+
+	switch e.Encoding {
+	case "", EncodingJSON:
+		request.UAST = file.UAST
+	case EncodingProtobuf:
+		data, err := file.UAST.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		request.UASTProtobuf = data
+	default:
+		return nil, fmt.Errorf("unknown encoding %s", e.Encoding)
+	}
+	return json.Marshal(request)
+}
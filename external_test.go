@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// TestExternalHelper is the external tool run by the tests, it does nothing
+// unless run by them.
+func TestExternalHelper(t *testing.T) {
+	mode := os.Getenv("EXTERNAL_HELPER")
+	if mode == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	request := &ExternalRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	root := request.UAST
+	if request.UASTProtobuf != nil {
+		root = &uast.Node{}
+		if err := root.Unmarshal(request.UASTProtobuf); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	response := &ExternalResponse{}
+	switch mode {
+	case "count":
+		nodes := 0
+		var visit func(n *uast.Node)
+		visit = func(n *uast.Node) {
+			nodes++
+			for _, child := range n.Children {
+				visit(child)
+			}
+		}
+		visit(root)
+		response.Metrics = []*Metric{{Name: "nodes:" + request.File.Language, Value: float64(nodes)}}
+		response.Findings = []*Finding{{Line: 1, Rule: "root", Message: root.InternalType}}
+	case "error":
+		response.Error = "something went wrong"
+	case "fail":
+		os.Exit(1)
+	case "sleep":
+		time.Sleep(10 * time.Second)
+	case "wrapper":
+		// exits leaving a child with its standard output
+		child := exec.Command("sleep", "10")
+		child.Stdout = os.Stdout
+		if err := child.Start(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+	json.NewEncoder(os.Stdout).Encode(response)
+}
+
+func externalHelper(mode string) External {
+	os.Setenv("EXTERNAL_HELPER", mode)
+	return External{Command: os.Args[0], Args: []string{"-test.run=TestExternalHelper"}}
+}
+
+func TestExternal(t *testing.T) {
+	require := require.New(t)
+	defer os.Unsetenv("EXTERNAL_HELPER")
+
+	file := &File{Path: "ifelse.java", UAST: readFixture(t, "fixtures/npath/ifelse.java.json")}
+	for _, encoding := range []string{EncodingJSON, EncodingProtobuf} {
+		e := externalHelper("count")
+		e.Encoding = encoding
+
+		results := &Results{}
+		require.NoError(e.Run(file, results), encoding)
+		require.Equal(&Results{
+			Metrics:  []*Metric{{File: "ifelse.java", Name: "nodes:java", Value: 25}},
+			Findings: []*Finding{{File: "ifelse.java", Line: 1, Rule: "root", Message: "CompilationUnit"}},
+		}, results, encoding)
+	}
+
+	for _, mode := range []string{"error", "fail"} {
+		err := externalHelper(mode).Run(file, &Results{})
+		require.True(ErrExternal.Is(err), mode)
+	}
+
+	e := externalHelper("sleep")
+	e.Timeout = 100 * time.Millisecond
+	err := e.Run(file, &Results{})
+	require.True(ErrExternal.Is(err))
+	require.Contains(err.Error(), "timed out")
+}
+
+func TestExternalTimeoutWrapper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no process groups")
+	}
+	require := require.New(t)
+	defer os.Unsetenv("EXTERNAL_HELPER")
+
+	e := externalHelper("wrapper")
+	e.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	err := e.Run(&File{Path: "a", UAST: &uast.Node{}}, &Results{})
+	require.True(ErrExternal.Is(err))
+	require.Contains(err.Error(), "timed out")
+	require.True(time.Since(start) < 5*time.Second, "took %s", time.Since(start))
+}
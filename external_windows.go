@@ -0,0 +1,11 @@
+package tools
+
+import "os/exec"
+
+// setProcessGroup does nothing, Windows has no process groups to kill.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of the command, but not its children.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
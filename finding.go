@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Finding is a problem found by a tool at some position of a file.
+type Finding struct {
+	File string `json:"file"`
+	// Line and Col are 1-based, zero if unknown.
+	Line int `json:"line"`
+	Col  int `json:"col,omitempty"`
+	// Rule identifies the check that produced the finding.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Severity is how serious the problem is, such as error or warning,
+	// empty if the tool doesn't tell.
+	Severity string `json:"severity,omitempty"`
+}
+
+func (f *Finding) String() string {
+	pos := fmt.Sprintf("%s:%d", f.File, f.Line)
+	if f.Col != 0 {
+		pos = fmt.Sprintf("%s:%d", pos, f.Col)
+	}
+	if f.Severity != "" {
+		return fmt.Sprintf("%s: %s: %s [%s]\n", pos, f.Severity, f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%s: %s [%s]\n", pos, f.Message, f.Rule)
+}
+
+// Metric is a value computed by a script or an external tool.
+type Metric struct {
+	File string `json:"file"`
+	// Line is the line of the node of the metric, zero if unknown or none.
+	Line  int     `json:"line,omitempty"`
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+func (m *Metric) String() string {
+	return fmt.Sprintf("File:%s, Line:%d, Metric:%s, Value:%g\n", m.File, m.Line, m.Name, m.Value)
+}
+
+// Results are the metrics and findings emitted by a script or an external
+// tool.
+type Results struct {
+	Metrics  []*Metric  `json:"metrics"`
+	Findings []*Finding `json:"findings"`
+}
+
+// Write writes the results in a format: text or json.
+func (r *Results) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		return r.WriteText(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		out := *r
+		if out.Metrics == nil {
+			out.Metrics = []*Metric{}
+		}
+		if out.Findings == nil {
+			out.Findings = []*Finding{}
+		}
+		return enc.Encode(out)
+	default:
+		return ErrUnknownFormat.New(format)
+	}
+}
+
+// WriteText writes the metrics followed by the findings.
+func (r *Results) WriteText(w io.Writer) error {
+	for _, m := range r.Metrics {
+		if _, err := fmt.Fprint(w, m); err != nil {
+			return err
+		}
+	}
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprint(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
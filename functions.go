@@ -0,0 +1,68 @@
+package tools
+
+import "gopkg.in/bblfsh/sdk.v1/uast"
+
+// Function is a function or method declaration found in a UAST.
+type Function struct {
+	// Name is the name of the function, empty if the driver didn't
+	// annotate one (e.g. anonymous functions).
+	Name string
+	// Node is the node with the Function and Declaration roles.
+	Node *uast.Node
+}
+
+// Body returns the body of the function, or nil if it has none (e.g.
+// abstract methods).
+func (f *Function) Body() *uast.Node {
+	bodies := childrenOfRoles(f.Node, []uast.Role{uast.Function, uast.Body}, nil)
+	if len(bodies) == 0 {
+		return nil
+	}
+	return bodies[0]
+}
+
+// Functions returns the function declarations contained in the node in
+// preorder. Functions nested in other functions are returned too.
+//
+// Some drivers also annotate function arguments as Function, Declaration,
+// so nodes with the Argument role are skipped.
+func Functions(n *uast.Node) []*Function {
+	var funcs []*Function
+	for _, decl := range deepChildrenOfRoles(n, []uast.Role{uast.Function, uast.Declaration}, []uast.Role{uast.Argument}) {
+		funcs = append(funcs, &Function{Name: functionName(decl), Node: decl})
+	}
+	return funcs
+}
+
+func isFunctionDeclaration(n *uast.Node) bool {
+	return containsRoles(n, []uast.Role{uast.Function, uast.Declaration}, []uast.Role{uast.Argument})
+}
+
+func functionName(decl *uast.Node) string {
+	if containsRoles(decl, []uast.Role{uast.Function, uast.Name}, nil) {
+		return decl.Token
+	}
+	names := childrenOfRoles(decl, []uast.Role{uast.Function, uast.Name}, nil)
+	if len(names) > 0 {
+		return names[0].Token
+	}
+	return ""
+}
+
+// nameOf returns the name a node stands for: its own token or, for nodes
+// wrapping identifiers such as types or callees, the first token found
+// under it. Qualified names return their last component.
+func nameOf(n *uast.Node) string {
+	if n.Token != "" {
+		return n.Token
+	}
+	if containsRoles(n, []uast.Role{uast.Qualified}, nil) && len(n.Children) > 0 {
+		return nameOf(n.Children[len(n.Children)-1])
+	}
+	for _, child := range n.Children {
+		if name := nameOf(child); name != "" {
+			return name
+		}
+	}
+	return ""
+}
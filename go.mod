@@ -12,6 +12,7 @@ require (
 	github.com/pkg/errors v0.8.0 // indirect
 	github.com/sirupsen/logrus v1.4.2 // indirect
 	github.com/stretchr/testify v1.3.0
+	go.starlark.net v0.0.0-20190702223751-32f345186213
 	golang.org/x/crypto v0.0.0-20170825220121-81e90905daef // indirect
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
 	google.golang.org/genproto v0.0.0-20170711235230-b0a3dcfcd1a9 // indirect
@@ -20,4 +21,5 @@ require (
 	gopkg.in/bblfsh/sdk.v1 v1.2.0
 	gopkg.in/gemnasium/logrus-airbrake-hook.v2 v2.1.2 // indirect
 	gopkg.in/src-d/go-errors.v1 v1.0.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,314 @@
+package tools
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// ExportGraph writes the UAST of the analyzed files as graphs for graph
+// neural networks, in the way of Allamanis et al. "Learning to Represent
+// Programs with Graphs": https://arxiv.org/abs/1711.00740
+//
+// Every UAST node is a graph node, with its internal type, roles, token and
+// positions as features, and the edges are:
+// * child: from every node to its children
+// * next_token: from every node with a token to the next one, in the order
+//   returned by Tokens
+// * last_use: from every identifier in a function to the previous
+//   occurrence of the same identifier in the function, in the order
+//   returned by Tokens. Functions nested in another one are a scope of
+//   their own, left out of the outer one
+// * last_write: from every identifier in a function to the previous
+//   occurrence of the same identifier being written: the left side of an
+//   assignment, an incremented or decremented identifier, or the name of a
+//   declaration
+//
+// Node identifiers are unique across all the files.
+type ExportGraph struct {
+	// Format is the output format: json, graphml or edges, a line with the
+	// source node, target node and kind of every edge.
+	Format string
+}
+
+// ExportGraphOptions are the options of ExportGraph.
+type ExportGraphOptions struct {
+	Format string `long:"format" description:"output format: json, graphml or edges" default:"json"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "export-graph",
+		Description: "Run UAST graph export for graph neural networks",
+		Options:     func() interface{} { return &ExportGraphOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*ExportGraphOptions)
+			return ExportGraph{Format: o.Format}, nil
+		},
+	})
+}
+
+// Graph is the graph of the UAST of a file.
+type Graph struct {
+	File  string       `json:"file"`
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a UAST node in a Graph.
+type GraphNode struct {
+	ID           int            `json:"id"`
+	InternalType string         `json:"internal_type"`
+	Roles        []string       `json:"roles"`
+	Token        string         `json:"token,omitempty"`
+	Start        *GraphPosition `json:"start,omitempty"`
+	End          *GraphPosition `json:"end,omitempty"`
+}
+
+// GraphPosition is the position of a GraphNode in the source code.
+type GraphPosition struct {
+	Offset uint32 `json:"offset"`
+	Line   uint32 `json:"line"`
+	Col    uint32 `json:"col"`
+}
+
+// GraphEdge is an edge of a Graph of the given kind.
+type GraphEdge struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Kind string `json:"kind"`
+}
+
+const (
+	ChildEdge     = "child"
+	NextTokenEdge = "next_token"
+	LastUseEdge   = "last_use"
+	LastWriteEdge = "last_write"
+)
+
+func (eg ExportGraph) Exec(n *uast.Node) error {
+	return eg.ExecFiles([]*File{{UAST: n}})
+}
+
+func (eg ExportGraph) ExecFiles(files []*File) error {
+	graphs := Graphs(files)
+	switch eg.Format {
+	case "", "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graphs)
+	case "graphml":
+		return WriteGraphML(os.Stdout, graphs)
+	case "edges":
+		for _, g := range graphs {
+			for _, e := range g.Edges {
+				fmt.Printf("%d %d %s\n", e.From, e.To, e.Kind)
+			}
+		}
+		return nil
+	default:
+		return ErrUnknownFormat.New(eg.Format)
+	}
+}
+
+// Graphs returns the graphs of the files, with node identifiers unique
+// across all of them.
+func Graphs(files []*File) []*Graph {
+	var graphs []*Graph
+	next := 0
+	for _, file := range files {
+		g := GraphOf(file.UAST, next)
+		g.File = file.Path
+		next += len(g.Nodes)
+		graphs = append(graphs, g)
+	}
+	return graphs
+}
+
+// GraphOf returns the graph of a UAST, numbering its nodes in preorder from
+// first.
+func GraphOf(n *uast.Node, first int) *Graph {
+	g := &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+	ids := make(map[*uast.Node]int)
+
+	var add func(n *uast.Node) int
+	add = func(n *uast.Node) int {
+		id := first + len(g.Nodes)
+		ids[n] = id
+		g.Nodes = append(g.Nodes, &GraphNode{
+			ID:           id,
+			InternalType: n.InternalType,
+			Roles:        roleNames(n.Roles),
+			Token:        n.Token,
+			Start:        graphPosition(n.StartPosition),
+			End:          graphPosition(n.EndPosition),
+		})
+		for _, child := range n.Children {
+			g.Edges = append(g.Edges, &GraphEdge{From: id, To: add(child), Kind: ChildEdge})
+		}
+		return id
+	}
+	add(n)
+
+	// nodes with the Infix or Postfix roles are copied without children
+	// by walkTokens, right under the original node
+	original := func(p uast.Path) *uast.Node {
+		if _, ok := ids[p.Node()]; !ok && len(p) > 1 {
+			return p[len(p)-2]
+		}
+		return p.Node()
+	}
+
+	prev := -1
+	walkTokens(n, func(p uast.Path) {
+		id := ids[original(p)]
+		if prev >= 0 {
+			g.Edges = append(g.Edges, &GraphEdge{From: prev, To: id, Kind: NextTokenEdge})
+		}
+		prev = id
+	})
+
+	for _, f := range Functions(n) {
+		lastUse := make(map[string]int)
+		lastWrite := make(map[string]int)
+		walkTokens(f.Node, func(p uast.Path) {
+			node := original(p)
+			if inNestedFunction(p) || !containsRoles(node, []uast.Role{uast.Identifier}, nil) {
+				return
+			}
+			id := ids[node]
+			if use, ok := lastUse[node.Token]; ok {
+				g.Edges = append(g.Edges, &GraphEdge{From: id, To: use, Kind: LastUseEdge})
+			}
+			if write, ok := lastWrite[node.Token]; ok {
+				g.Edges = append(g.Edges, &GraphEdge{From: id, To: write, Kind: LastWriteEdge})
+			}
+			lastUse[node.Token] = id
+			if isWrite(p) {
+				lastWrite[node.Token] = id
+			}
+		})
+	}
+	return g
+}
+
+// inNestedFunction tells if the path, starting at a function, goes through
+// a function declared inside it, whose identifiers are handled on its own.
+func inNestedFunction(p uast.Path) bool {
+	for _, n := range p[1:] {
+		if isFunctionDeclaration(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWrite tells if the identifier at the end of the path is being written:
+// it's the left side of an assignment, incremented, decremented or the name
+// of a declaration.
+func isWrite(p uast.Path) bool {
+	n := p.Node()
+	if containsRoles(n, []uast.Role{uast.Assignment, uast.Left}, nil) {
+		return true
+	}
+	if len(p) < 2 {
+		return false
+	}
+	parent := p[len(p)-2]
+	if containsRoles(parent, []uast.Role{uast.Assignment, uast.Left}, nil) ||
+		containsRoles(parent, []uast.Role{uast.Increment}, nil) ||
+		containsRoles(parent, []uast.Role{uast.Decrement}, nil) {
+		return true
+	}
+	if containsRoles(parent, []uast.Role{uast.Declaration}, nil) {
+		for _, child := range parent.Children {
+			if containsRoles(child, []uast.Role{uast.Identifier}, nil) {
+				return child == n
+			}
+		}
+	}
+	return false
+}
+
+func graphPosition(pos *uast.Position) *GraphPosition {
+	if pos == nil {
+		return nil
+	}
+	return &GraphPosition{Offset: pos.Offset, Line: pos.Line, Col: pos.Col}
+}
+
+// graphMLKeys are the attributes of the nodes and edges in GraphML, and
+// their types.
+var graphMLKeys = []struct{ id, target, kind string }{
+	{"file", "graph", "string"},
+	{"internal_type", "node", "string"},
+	{"roles", "node", "string"},
+	{"token", "node", "string"},
+	{"start_offset", "node", "int"},
+	{"start_line", "node", "int"},
+	{"start_col", "node", "int"},
+	{"end_offset", "node", "int"},
+	{"end_line", "node", "int"},
+	{"end_col", "node", "int"},
+	{"kind", "edge", "string"},
+}
+
+// WriteGraphML writes the graphs as a GraphML document, with one graph
+// element per file: http://graphml.graphdrawing.org
+func WriteGraphML(w io.Writer, graphs []*Graph) error {
+	var out strings.Builder
+	out.WriteString(xml.Header)
+	out.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	for _, key := range graphMLKeys {
+		fmt.Fprintf(&out, "  <key id=%q for=%q attr.name=%q attr.type=%q/>\n", key.id, key.target, key.id, key.kind)
+	}
+
+	data := func(key, value string) {
+		fmt.Fprintf(&out, "      <data key=%q>%s</data>\n", key, xmlEscape(value))
+	}
+	position := func(prefix string, pos *GraphPosition) {
+		if pos == nil {
+			return
+		}
+		data(prefix+"_offset", fmt.Sprint(pos.Offset))
+		data(prefix+"_line", fmt.Sprint(pos.Line))
+		data(prefix+"_col", fmt.Sprint(pos.Col))
+	}
+
+	for i, g := range graphs {
+		fmt.Fprintf(&out, "  <graph id=\"g%d\" edgedefault=\"directed\">\n", i)
+		fmt.Fprintf(&out, "    <data key=\"file\">%s</data>\n", xmlEscape(g.File))
+		for _, n := range g.Nodes {
+			fmt.Fprintf(&out, "    <node id=\"n%d\">\n", n.ID)
+			data("internal_type", n.InternalType)
+			data("roles", strings.Join(n.Roles, "|"))
+			if n.Token != "" {
+				data("token", n.Token)
+			}
+			position("start", n.Start)
+			position("end", n.End)
+			out.WriteString("    </node>\n")
+		}
+		for _, e := range g.Edges {
+			fmt.Fprintf(&out, "    <edge source=\"n%d\" target=\"n%d\">\n", e.From, e.To)
+			data("kind", e.Kind)
+			out.WriteString("    </edge>\n")
+		}
+		out.WriteString("  </graph>\n")
+	}
+	out.WriteString("</graphml>\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func xmlEscape(s string) string {
+	var out strings.Builder
+	xml.EscapeText(&out, []byte(s))
+	return out.String()
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func graphFixture() *uast.Node {
+	// f(x) { x = x + 1; return x }
+	return &uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "Method", Roles: []uast.Role{uast.Function, uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "Name", Roles: []uast.Role{uast.Function, uast.Name, uast.Identifier}, Token: "f",
+				StartPosition: &uast.Position{Offset: 0, Line: 1, Col: 1}},
+			{InternalType: "Arg", Roles: []uast.Role{uast.Function, uast.Argument, uast.Declaration}, Children: []*uast.Node{
+				{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: "x"},
+			}},
+			{InternalType: "Assign", Roles: []uast.Role{uast.Assignment}, Children: []*uast.Node{
+				{InternalType: "Name", Roles: []uast.Role{uast.Identifier, uast.Assignment, uast.Left}, Token: "x"},
+				{InternalType: "Plus", Roles: []uast.Role{uast.Add, uast.Infix}, Token: "+", Children: []*uast.Node{
+					{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: "x"},
+					{InternalType: "Number", Roles: []uast.Role{uast.Literal}, Token: "1"},
+				}},
+			}},
+			{InternalType: "Return", Roles: []uast.Role{uast.Return}, Children: []*uast.Node{
+				{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: "x"},
+			}},
+		}},
+	}}
+}
+
+func graphEdges(g *Graph, kind string) [][2]int {
+	var edges [][2]int
+	for _, e := range g.Edges {
+		if e.Kind == kind {
+			edges = append(edges, [2]int{e.From, e.To})
+		}
+	}
+	return edges
+}
+
+func TestGraphOf(t *testing.T) {
+	require := require.New(t)
+
+	g := GraphOf(graphFixture(), 0)
+	require.Len(g.Nodes, 12)
+	require.Equal(&GraphNode{
+		ID:           2,
+		InternalType: "Name",
+		Roles:        []string{"Function", "Name", "Identifier"},
+		Token:        "f",
+		Start:        &GraphPosition{Offset: 0, Line: 1, Col: 1},
+	}, g.Nodes[2])
+
+	require.Len(graphEdges(g, ChildEdge), 11)
+	require.Contains(graphEdges(g, ChildEdge), [2]int{7, 9})
+	require.Equal([][2]int{{2, 4}, {4, 6}, {6, 8}, {8, 7}, {7, 9}, {9, 11}}, graphEdges(g, NextTokenEdge))
+	require.Equal([][2]int{{6, 4}, {8, 6}, {11, 8}}, graphEdges(g, LastUseEdge))
+	require.Equal([][2]int{{6, 4}, {8, 6}, {11, 6}}, graphEdges(g, LastWriteEdge))
+
+	graphs := Graphs([]*File{{Path: "a", UAST: graphFixture()}, {Path: "b", UAST: graphFixture()}})
+	require.Len(graphs, 2)
+	require.Equal("b", graphs[1].File)
+	require.Equal(12, graphs[1].Nodes[0].ID)
+	require.Equal([2]int{14, 16}, graphEdges(graphs[1], NextTokenEdge)[0])
+}
+
+func TestGraphOfNestedFunction(t *testing.T) {
+	require := require.New(t)
+
+	name := func(token string, roles ...uast.Role) *uast.Node {
+		return &uast.Node{InternalType: "Name", Roles: append(roles, uast.Identifier), Token: token}
+	}
+	function := func(fname string, body *uast.Node) *uast.Node {
+		return &uast.Node{InternalType: "Method", Roles: []uast.Role{uast.Function, uast.Declaration}, Children: []*uast.Node{
+			name(fname, uast.Function, uast.Name),
+			{InternalType: "Arg", Roles: []uast.Role{uast.Function, uast.Argument, uast.Declaration}, Children: []*uast.Node{name("x")}},
+			body,
+			{InternalType: "Return", Roles: []uast.Role{uast.Return}, Children: []*uast.Node{name("x")}},
+		}}
+	}
+	// f(x) { g(x) { return x }; return x }
+	root := &uast.Node{InternalType: "File", Children: []*uast.Node{
+		function("f", function("g", &uast.Node{InternalType: "Pass"})),
+	}}
+
+	g := GraphOf(root, 0)
+	require.Len(g.Nodes, 14)
+	require.ElementsMatch([][2]int{{13, 4}, {11, 8}}, graphEdges(g, LastUseEdge))
+	require.ElementsMatch([][2]int{{13, 4}, {11, 8}}, graphEdges(g, LastWriteEdge))
+}
+
+func TestWriteGraphML(t *testing.T) {
+	require := require.New(t)
+
+	g := GraphOf(&uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "String", Roles: []uast.Role{uast.Literal}, Token: `"a<b"`},
+	}}, 0)
+	g.File = "a&b.py"
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(WriteGraphML(buf, []*Graph{g}))
+	require.Contains(buf.String(), `<data key="file">a&amp;b.py</data>`)
+	require.Contains(buf.String(), `<data key="token">&#34;a&lt;b&#34;</data>`)
+	require.Contains(buf.String(), `<edge source="n0" target="n1">`)
+
+	var doc struct {
+		Graphs []struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+		} `xml:"graph"`
+	}
+	require.NoError(xml.Unmarshal(buf.Bytes(), &doc))
+	require.Len(doc.Graphs, 1)
+	require.Len(doc.Graphs[0].Nodes, 2)
+}
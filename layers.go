@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrLayerViolations = errors.NewKind("%d layering rule violations found")
+	ErrUnknownLayer    = errors.NewKind("unknown layer in layering rules: %s")
+	ErrInvalidGlob     = errors.NewKind("invalid glob in layer %s: %s")
+)
+
+// Layers checks every import of the analyzed files against a set of
+// architecture layering rules, e.g. "domain must not import infrastructure",
+// and fails with ErrLayerViolations if any of them is broken.
+//
+// The layer of a file is the first one with a glob matching its path or its
+// package, and the layer of an import the first one matching the imported
+// path or the analyzed package it resolves to, as done by Deps. A layer may
+// always depend on itself, and files or imports matching no layer are not
+// checked.
+type Layers struct {
+	Rules *LayerRules
+}
+
+// LayersOptions are the options of Layers.
+type LayersOptions struct {
+	Rules string `long:"rules" description:"YAML file with the layering rules" required:"true"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "layers",
+		Description: "Run architecture layering rules check",
+		Options:     func() interface{} { return &LayersOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			rules, err := LoadLayerRules(env.Options.(*LayersOptions).Rules)
+			if err != nil {
+				return nil, err
+			}
+			return Layers{Rules: rules}, nil
+		},
+	})
+}
+
+// LayerRules are the layers of an architecture and the allowed dependencies
+// between them. They are usually loaded from a YAML file such as:
+//
+//   layers:
+//     - name: domain
+//       match: ["app.domain", "app.domain.**"]
+//     - name: infrastructure
+//       match: ["app.infra", "app.infra.**", "src/infra/**"]
+//   allow:
+//     infrastructure: [domain]
+type LayerRules struct {
+	Layers []*Layer `yaml:"layers"`
+	// Allow maps every layer to the layers it may depend on.
+	Allow map[string][]string `yaml:"allow"`
+	// Module is the import path of the root of the project, as in Deps.
+	Module string `yaml:"module"`
+}
+
+// Layer is a named set of files and packages.
+type Layer struct {
+	Name string `yaml:"name"`
+	// Match are globs matched against paths and package names. A `*`
+	// matches any sequence of characters but `/`, `**` any sequence of
+	// characters and `?` a single character but `/`.
+	Match []string `yaml:"match"`
+
+	globs []*regexp.Regexp
+}
+
+func (l Layers) Exec(n *uast.Node) error {
+	return l.ExecFiles([]*File{{UAST: n}})
+}
+
+func (l Layers) ExecFiles(files []*File) error {
+	violations := l.Rules.Check(files)
+	for _, v := range violations {
+		fmt.Print(v)
+	}
+	if len(violations) > 0 {
+		return ErrLayerViolations.New(len(violations))
+	}
+	return nil
+}
+
+// LoadLayerRules reads the layering rules from a YAML file.
+func LoadLayerRules(path string) (*LayerRules, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLayerRules(content)
+}
+
+// ParseLayerRules parses layering rules in YAML.
+func ParseLayerRules(content []byte) (*LayerRules, error) {
+	rules := &LayerRules{}
+	if err := yaml.Unmarshal(content, rules); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, layer := range rules.Layers {
+		names[layer.Name] = true
+		for _, glob := range layer.Match {
+			re, err := globRegexp(glob)
+			if err != nil {
+				return nil, ErrInvalidGlob.New(layer.Name, glob)
+			}
+			layer.globs = append(layer.globs, re)
+		}
+	}
+	for from, tos := range rules.Allow {
+		for _, name := range append([]string{from}, tos...) {
+			if !names[name] {
+				return nil, ErrUnknownLayer.New(name)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Check returns a finding for every import in the files breaking the rules.
+func (lr *LayerRules) Check(files []*File) []*Finding {
+	var packages []string
+	filePackages := make([]string, len(files))
+	for i, file := range files {
+		filePackages[i] = PackageOf(file)
+		packages = append(packages, filePackages[i])
+	}
+
+	var violations []*Finding
+	for i, file := range files {
+		from := lr.layerOf(file.Path, filePackages[i])
+		if from == nil {
+			continue
+		}
+		for _, imp := range Imports(file.UAST) {
+			to := lr.layerOf(imp.Path, resolveImport(packages, lr.Module, file.Path, imp.Path))
+			if to == nil || lr.allowed(from, to) {
+				continue
+			}
+			violations = append(violations, &Finding{
+				File:    file.Path,
+				Line:    imp.Line,
+				Rule:    "layers",
+				Message: fmt.Sprintf("layer %s must not depend on layer %s (import %s)", from.Name, to.Name, imp.Path),
+			})
+		}
+	}
+	return violations
+}
+
+func (lr *LayerRules) layerOf(names ...string) *Layer {
+	for _, layer := range lr.Layers {
+		for _, glob := range layer.globs {
+			for _, name := range names {
+				if glob.MatchString(name) {
+					return layer
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (lr *LayerRules) allowed(from, to *Layer) bool {
+	if from == to {
+		return true
+	}
+	for _, name := range lr.Allow[from.Name] {
+		if name == to.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp compiles a glob as described in Layer.Match.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString(`[^/]*`)
+		case c == '?':
+			expr.WriteString(`[^/]`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
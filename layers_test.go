@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testLayerRules = `
+layers:
+  - name: domain
+    match: ["app.domain", "app.domain.**"]
+  - name: infrastructure
+    match: ["app.infra", "app.infra.**"]
+  - name: web
+    match: ["web/**"]
+allow:
+  infrastructure: [domain]
+  web: [domain, infrastructure]
+`
+
+func TestLayerRules(t *testing.T) {
+	require := require.New(t)
+
+	rules, err := ParseLayerRules([]byte(testLayerRules))
+	require.NoError(err)
+
+	violations := rules.Check(depsFiles())
+	require.Equal([]*Finding{{
+		File:    "a.java",
+		Line:    3,
+		Rule:    "layers",
+		Message: "layer domain must not depend on layer infrastructure (import app.infra.Db)",
+	}}, violations)
+
+	require.True(ErrLayerViolations.Is(Layers{Rules: rules}.ExecFiles(depsFiles())))
+}
+
+func TestLayerRulesErrors(t *testing.T) {
+	require := require.New(t)
+
+	_, err := ParseLayerRules([]byte("layers:\n  - name: a\nallow:\n  a: [b]\n"))
+	require.True(ErrUnknownLayer.Is(err))
+
+	_, err = ParseLayerRules([]byte("layers: ["))
+	require.Error(err)
+}
+
+func TestGlobRegexp(t *testing.T) {
+	require := require.New(t)
+
+	for glob, cases := range map[string]map[string]bool{
+		"app.*":        {"app.domain": true, "app.domain.User": true, "app": false},
+		"app.**":       {"app.domain": true, "app.domain.User": true},
+		"src/?/*.go":   {"src/a/main.go": true, "src/ab/main.go": false},
+		"src/infra/*":  {"src/infra/db.py": true, "src/infra/db": true, "src/infra/sql/db.py": false},
+		"src/*/db.?ml": {"src/infra/db.xml": true, "src/infra/db.yaml": false},
+	} {
+		re, err := globRegexp(glob)
+		require.NoError(err)
+		for name, match := range cases {
+			require.Equal(match, re.MatchString(name), "%s ~ %s", glob, name)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrLintErrors      = errors.NewKind("%d lint errors found")
+	ErrInvalidLintRule = errors.NewKind("invalid lint rule %s: %s")
+)
+
+// Lint severities, from the most to the least serious.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Lint runs a set of declarative rules over the UAST of the analyzed files
+// and prints a finding for every node matched by a rule, sorted by position.
+// It fails with ErrLintErrors if any finding has the error severity.
+//
+// Rules are written in terms of roles where possible, so the same rule
+// applies to every language with a driver. The language of a file, used to
+// scope the rules, is found as done by Stats.
+type Lint struct {
+	// Rules are the rules run, DefaultLintRules if nil.
+	Rules *LintRules
+	// Format is the output format: text or json.
+	Format string
+}
+
+// LintOptions are the options of Lint.
+type LintOptions struct {
+	Rules  string `long:"rules" description:"YAML file with the lint rules, the starter rule set if empty"`
+	Format string `long:"format" description:"output format: text or json" default:"text"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "lint",
+		Description: "Run declarative lint rules",
+		Options:     func() interface{} { return &LintOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*LintOptions)
+			l := Lint{Format: o.Format}
+			if o.Rules != "" {
+				var err error
+				if l.Rules, err = LoadLintRules(o.Rules); err != nil {
+					return nil, err
+				}
+			}
+			return l, nil
+		},
+	})
+}
+
+// LintRules are a set of lint rules. They are usually loaded from a YAML
+// file such as:
+//
+//   rules:
+//     - id: empty-if-body
+//       severity: warning
+//       message: empty if body
+//       query: //*[@role='If' and @role='Then' and (@role='Block' or @role='Body')][count(*) = 0]
+//     - id: println
+//       severity: info
+//       message: "call to {token}"
+//       languages: [java]
+//       match:
+//         roles: [Call, Callee]
+//         token: ^println$
+type LintRules struct {
+	Rules []*LintRule `yaml:"rules"`
+}
+
+// LintRule matches the nodes with a problem, with either a Query or a
+// NodeMatch.
+type LintRule struct {
+	ID string `yaml:"id"`
+	// Severity is error, warning or info, warning if empty.
+	Severity string `yaml:"severity"`
+	// Message is the message of the findings, where {type} and {token} are
+	// replaced by the internal type and token of the node matched.
+	Message string `yaml:"message"`
+	// Languages, if not empty, are the only languages the rule applies to.
+	Languages []string `yaml:"languages"`
+	// Query selects the nodes matched, see Query.
+	Query string `yaml:"query"`
+	// Match matches the nodes by their roles, internal type and token.
+	Match *NodeMatch `yaml:"match"`
+
+	query *Query
+}
+
+// NodeMatch matches the nodes with all the given properties.
+type NodeMatch struct {
+	// Roles are roles the node must have.
+	Roles []string `yaml:"roles"`
+	// Not are roles the node must not have.
+	Not []string `yaml:"not"`
+	// Type is a regular expression the internal type must match.
+	Type string `yaml:"type"`
+	// Token is a regular expression the token must match.
+	Token string `yaml:"token"`
+
+	roles, not []uast.Role
+	typ, token *regexp.Regexp
+}
+
+// DefaultLintRules are a starter set of rules for common problems.
+var DefaultLintRules = mustParseLintRules(`
+rules:
+  - id: empty-catch
+    severity: warning
+    message: empty catch block
+    query: >-
+      //*[@role='Try' and @role='Catch']
+        [count(*[@role='Block' or @role='Body'][count(*[not(@role='Comment')]) = 0]) > 0]
+  - id: empty-if-body
+    severity: warning
+    message: empty if body
+    query: >-
+      //*[@role='If' and @role='Then' and (@role='Block' or @role='Body')]
+        [count(*[not(@role='Comment')]) = 0]
+  - id: magic-number
+    severity: info
+    message: "magic number {token}, use a named constant"
+    query: >-
+      //*[@role='Literal' and @role='Number']
+        [not(@token='0' or @token='1' or @token='-1' or @token='2')]
+        [not(parent::*[@role='Declaration' and not(@role='Function')])]
+  - id: nested-ternary
+    severity: warning
+    message: nested conditional expression
+    languages: [java, javascript, typescript, csharp, python]
+    query: >-
+      //*[@type='ConditionalExpression' or @type='IfExp']
+        [ancestor::*[@type='ConditionalExpression' or @type='IfExp']]
+  - id: assignment-in-condition
+    severity: warning
+    message: assignment in condition
+    query: >-
+      //*[@role='Condition']/descendant-or-self::*
+        [@role='Assignment' and not(@role='Left') and not(@role='Right')]
+`)
+
+func mustParseLintRules(content string) *LintRules {
+	rules, err := ParseLintRules([]byte(content))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// LoadLintRules reads lint rules from a YAML file.
+func LoadLintRules(path string) (*LintRules, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLintRules(content)
+}
+
+// ParseLintRules parses lint rules in YAML.
+func ParseLintRules(content []byte) (*LintRules, error) {
+	rules := &LintRules{}
+	if err := yaml.Unmarshal(content, rules); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, ErrInvalidLintRule.New(rule.ID, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *LintRule) compile() error {
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityError, SeverityWarning, SeverityInfo:
+	default:
+		return fmt.Errorf("unknown severity %s", r.Severity)
+	}
+	if r.ID == "" {
+		return fmt.Errorf("no id")
+	}
+	if (r.Query == "") == (r.Match == nil) {
+		return fmt.Errorf("it must have either a query or a match")
+	}
+
+	var err error
+	if r.Query != "" {
+		r.query, err = ParseQuery(r.Query)
+		return err
+	}
+
+	m := r.Match
+	if m.roles, err = ParseRoles(m.Roles); err != nil {
+		return err
+	}
+	if m.not, err = ParseRoles(m.Not); err != nil {
+		return err
+	}
+	if m.Type != "" {
+		if m.typ, err = regexp.Compile(m.Type); err != nil {
+			return err
+		}
+	}
+	if m.Token != "" {
+		if m.token, err = regexp.Compile(m.Token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches returns whether the node matches. The token of the nodes without
+// one is their token property, set by some drivers instead.
+func (m *NodeMatch) Matches(n *uast.Node) bool {
+	if !containsRoles(n, m.roles, m.not) {
+		return false
+	}
+	if m.typ != nil && !m.typ.MatchString(n.InternalType) {
+		return false
+	}
+	return m.token == nil || m.token.MatchString(nodeToken(n))
+}
+
+// nodeToken returns the token of the node, or its token property if it has
+// none.
+func nodeToken(n *uast.Node) string {
+	if n.Token == "" {
+		return n.Properties["token"]
+	}
+	return n.Token
+}
+
+func (l Lint) Exec(n *uast.Node) error {
+	return l.ExecFiles([]*File{{UAST: n}})
+}
+
+func (l Lint) ExecFiles(files []*File) error {
+	rules := l.Rules
+	if rules == nil {
+		rules = DefaultLintRules
+	}
+
+	var findings []*Finding
+	for _, file := range files {
+		findings = append(findings, rules.Check(file)...)
+	}
+
+	switch l.Format {
+	case "", "text":
+		for _, f := range findings {
+			fmt.Print(f)
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if findings == nil {
+			findings = []*Finding{}
+		}
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	default:
+		return ErrUnknownFormat.New(l.Format)
+	}
+
+	errs := 0
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return ErrLintErrors.New(errs)
+	}
+	return nil
+}
+
+// Check returns the findings of the rules applying to the language of the
+// file, sorted by position and then by rule.
+func (lr *LintRules) Check(file *File) []*Finding {
+	lang := fileLanguage(file)
+	var findings []*Finding
+	for _, rule := range lr.Rules {
+		if !rule.appliesTo(lang) {
+			continue
+		}
+		for _, n := range rule.nodes(file.UAST) {
+			f := &Finding{
+				File:     file.Path,
+				Rule:     rule.ID,
+				Severity: rule.Severity,
+				Message:  strings.NewReplacer("{type}", n.InternalType, "{token}", nodeToken(n)).Replace(rule.Message),
+			}
+			if pos := startPosition(n); pos != nil {
+				f.Line, f.Col = int(pos.Line), int(pos.Col)
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Col != b.Col {
+			return a.Col < b.Col
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+func (r *LintRule) appliesTo(lang string) bool {
+	if len(r.Languages) == 0 {
+		return true
+	}
+	for _, l := range r.Languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodes returns the nodes of the tree matched by the rule.
+func (r *LintRule) nodes(root *uast.Node) []*uast.Node {
+	if r.query != nil {
+		return r.query.Select(root)
+	}
+
+	var nodes []*uast.Node
+	var visit func(n *uast.Node)
+	visit = func(n *uast.Node) {
+		if r.Match.Matches(n) {
+			nodes = append(nodes, n)
+		}
+		for _, child := range n.Children {
+			visit(child)
+		}
+	}
+	visit(root)
+	return nodes
+}
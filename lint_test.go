@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func lintFixture(path string) *File {
+	pos := func(line uint32) *uast.Position {
+		return &uast.Position{Line: line, Col: 1}
+	}
+	roles := func(r ...uast.Role) []uast.Role { return r }
+	number := func(line uint32, token string) *uast.Node {
+		return &uast.Node{InternalType: "NumberLiteral", Roles: roles(uast.Expression, uast.Literal, uast.Number),
+			Properties: map[string]string{"token": token}, StartPosition: pos(line)}
+	}
+	ternary := func(line uint32, children ...*uast.Node) *uast.Node {
+		return &uast.Node{InternalType: "ConditionalExpression", Roles: roles(uast.Expression), StartPosition: pos(line), Children: children}
+	}
+
+	return &File{Path: path, UAST: &uast.Node{InternalType: "Block", Roles: roles(uast.Block), Children: []*uast.Node{
+		// static final int MAX = 100;
+		{InternalType: "VariableDeclarationFragment", Roles: roles(uast.Declaration, uast.Variable), StartPosition: pos(1), Children: []*uast.Node{
+			number(1, "100"),
+		}},
+		// try { x = 42; } catch (E e) {}
+		{InternalType: "TryStatement", Roles: roles(uast.Statement, uast.Try), StartPosition: pos(2), Children: []*uast.Node{
+			{InternalType: "Block", Roles: roles(uast.Try, uast.Body, uast.Block), Children: []*uast.Node{
+				{InternalType: "Assignment", Roles: roles(uast.Expression, uast.Assignment), StartPosition: pos(3), Children: []*uast.Node{
+					{InternalType: "SimpleName", Roles: roles(uast.Identifier, uast.Assignment, uast.Left), Token: "x", StartPosition: pos(3)},
+					number(3, "42"),
+				}},
+			}},
+			{InternalType: "CatchClause", Roles: roles(uast.Try, uast.Catch), StartPosition: pos(4), Children: []*uast.Node{
+				{InternalType: "Block", Roles: roles(uast.Statement, uast.Block), Children: []*uast.Node{
+					{InternalType: "LineComment", Roles: roles(uast.Comment), StartPosition: pos(5)},
+				}},
+			}},
+		}},
+		// if ((x = 1) > 0) {}
+		{InternalType: "IfStatement", Roles: roles(uast.Statement, uast.If), Token: "if", StartPosition: pos(6), Children: []*uast.Node{
+			{InternalType: "InfixExpression", Roles: roles(uast.If, uast.Condition, uast.Binary), StartPosition: pos(6), Children: []*uast.Node{
+				{InternalType: "Assignment", Roles: roles(uast.Expression, uast.Assignment), StartPosition: pos(6), Children: []*uast.Node{
+					{InternalType: "SimpleName", Roles: roles(uast.Identifier, uast.Assignment, uast.Left), Token: "x", StartPosition: pos(6)},
+					number(6, "1"),
+				}},
+				number(6, "0"),
+			}},
+			{InternalType: "Block", Roles: roles(uast.If, uast.Then, uast.Block), StartPosition: pos(6)},
+		}},
+		// a ? (b ? 7 : 0) : 1
+		ternary(7, &uast.Node{InternalType: "SimpleName", Roles: roles(uast.Identifier), Token: "a"},
+			ternary(8, number(8, "7"), number(8, "0")), number(7, "1")),
+		// if (done) return;
+		{InternalType: "IfStatement", Roles: roles(uast.Statement, uast.If), Token: "if", StartPosition: pos(9), Children: []*uast.Node{
+			{InternalType: "SimpleName", Roles: roles(uast.Identifier, uast.If, uast.Condition), Token: "done", StartPosition: pos(9)},
+			{InternalType: "ReturnStatement", Roles: roles(uast.Statement, uast.Return, uast.If, uast.Then), StartPosition: pos(9)},
+		}},
+	}}}
+}
+
+func TestDefaultLintRules(t *testing.T) {
+	require := require.New(t)
+
+	findings := DefaultLintRules.Check(lintFixture("A.java"))
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	require.Equal([]string{
+		"A.java:3:1: info: magic number 42, use a named constant [magic-number]\n",
+		"A.java:4:1: warning: empty catch block [empty-catch]\n",
+		"A.java:6:1: warning: assignment in condition [assignment-in-condition]\n",
+		"A.java:6:1: warning: empty if body [empty-if-body]\n",
+		"A.java:8:1: info: magic number 7, use a named constant [magic-number]\n",
+		"A.java:8:1: warning: nested conditional expression [nested-ternary]\n",
+	}, got)
+
+	// nested-ternary is scoped to languages with such expressions
+	for _, f := range DefaultLintRules.Check(lintFixture("a.go")) {
+		require.NotEqual("nested-ternary", f.Rule)
+	}
+
+	require.NoError(Lint{}.ExecFiles([]*File{lintFixture("A.java")}))
+}
+
+func TestLintRules(t *testing.T) {
+	require := require.New(t)
+
+	rules, err := ParseLintRules([]byte(`
+rules:
+  - id: big-number
+    severity: error
+    message: "{type} {token} is too big"
+    languages: [Java]
+    match:
+      roles: [Literal, Number]
+      not: [Declaration]
+      type: Literal$
+      token: ^[0-9]{2}$
+`))
+	require.NoError(err)
+
+	require.Equal([]*Finding{{
+		File:     "A.java",
+		Line:     3,
+		Col:      1,
+		Rule:     "big-number",
+		Message:  "NumberLiteral 42 is too big",
+		Severity: SeverityError,
+	}}, rules.Check(lintFixture("A.java")))
+	require.Empty(rules.Check(lintFixture("a.py")))
+
+	err = Lint{Rules: rules}.ExecFiles([]*File{lintFixture("A.java")})
+	require.True(ErrLintErrors.Is(err))
+}
+
+func TestLintRulesErrors(t *testing.T) {
+	require := require.New(t)
+
+	for _, content := range []string{
+		"rules:\n  - id: a\n    severity: fatal\n    query: //*\n",
+		"rules:\n  - severity: info\n    query: //*\n",
+		"rules:\n  - id: a\n",
+		"rules:\n  - id: a\n    query: //*\n    match: {roles: [Call]}\n",
+		"rules:\n  - id: a\n    query: //*[\n",
+		"rules:\n  - id: a\n    match: {roles: [Nope]}\n",
+		"rules:\n  - id: a\n    match: {token: '('}\n",
+	} {
+		_, err := ParseLintRules([]byte(content))
+		require.True(ErrInvalidLintRule.Is(err), content)
+	}
+}
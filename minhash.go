@@ -0,0 +1,410 @@
+package tools
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var (
+	ErrFunctionNotFound = errors.NewKind("function not found: %s")
+	ErrInvalidLSHBands  = errors.NewKind("invalid LSH bands and rows: %d and %d")
+)
+
+// Index adds the functions of the analyzed files to a similarity index on
+// disk, created if it doesn't exist, so Similar can find the ones looking
+// like a given function. Functions of files already in the index replace
+// the previous ones.
+//
+// Functions are compared by the Jaccard similarity of their shingles, as
+// given by FunctionShingles, which is estimated with MinHash signatures of
+// Bands * Rows values and looked up with locality-sensitive hashing: two
+// functions are candidates if all the Rows values of any of the Bands of
+// their signatures are equal. See chapter 3 of "Mining of Massive Datasets":
+// http://www.mmds.org
+type Index struct {
+	// Path is the file of the index.
+	Path string
+	// Bands and Rows are the ones of new indexes, 32 and 4 if zero, the
+	// ones of an existing index are kept.
+	Bands int
+	Rows  int
+}
+
+// IndexOptions are the options of Index.
+type IndexOptions struct {
+	Path  string `long:"index" description:"file of the similarity index, created if it doesn't exist" required:"true"`
+	Bands int    `long:"bands" description:"number of LSH bands of a new index" default:"32"`
+	Rows  int    `long:"rows" description:"number of MinHash values per LSH band of a new index" default:"4"`
+}
+
+// Similar prints the functions of a similarity index created by Index that
+// are the most similar to the functions with the given name in the analyzed
+// files.
+type Similar struct {
+	Index *SimilarityIndex
+	// Function is the name of the functions to look for.
+	Function string
+	// Limit is the maximum number of results per function, zero for all.
+	Limit int
+	// Threshold is the minimum estimated Jaccard similarity of the results.
+	Threshold float64
+}
+
+// SimilarOptions are the options of Similar.
+type SimilarOptions struct {
+	Index     string  `long:"index" description:"file of the similarity index" required:"true"`
+	Function  string  `long:"function" description:"name of the function to look for" required:"true"`
+	Limit     int     `long:"limit" description:"maximum number of results, zero for all" default:"10"`
+	Threshold float64 `long:"threshold" description:"minimum estimated Jaccard similarity of the results" default:"0"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "index",
+		Description: "Run function similarity indexing",
+		Options:     func() interface{} { return &IndexOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*IndexOptions)
+			return Index{Path: o.Path, Bands: o.Bands, Rows: o.Rows}, nil
+		},
+	})
+	Register(&ToolInfo{
+		Name:        "similar",
+		Description: "Run similar function search in an index",
+		Options:     func() interface{} { return &SimilarOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*SimilarOptions)
+			index, err := LoadSimilarityIndex(o.Index)
+			if err != nil {
+				return nil, err
+			}
+			return Similar{Index: index, Function: o.Function, Limit: o.Limit, Threshold: o.Threshold}, nil
+		},
+	})
+}
+
+// SimilarityIndex is a set of functions indexed by their MinHash
+// signatures.
+type SimilarityIndex struct {
+	Bands     int
+	Rows      int
+	Functions []*IndexedFunction
+
+	buckets map[uint64][]int
+}
+
+// IndexedFunction is a function of a SimilarityIndex.
+type IndexedFunction struct {
+	File      string
+	Name      string
+	Line      int
+	Signature []uint64
+}
+
+// SimilarFunction is a function found in a SimilarityIndex, with the
+// estimated Jaccard similarity of its shingles to the ones of the query.
+type SimilarFunction struct {
+	*IndexedFunction
+	Jaccard float64
+}
+
+func (i Index) Exec(n *uast.Node) error {
+	return i.ExecFiles([]*File{{UAST: n}})
+}
+
+func (i Index) ExecFiles(files []*File) error {
+	index, err := LoadSimilarityIndex(i.Path)
+	if os.IsNotExist(err) {
+		bands, rows := i.Bands, i.Rows
+		if bands == 0 {
+			bands = 32
+		}
+		if rows == 0 {
+			rows = 4
+		}
+		index, err = NewSimilarityIndex(bands, rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		index.Remove(file.Path)
+		funcs := Functions(file.UAST)
+		for _, f := range funcs {
+			index.Add(file.Path, f)
+		}
+		fmt.Printf("File:%s, Functions:%d\n", file.Path, len(funcs))
+	}
+	return index.Save(i.Path)
+}
+
+func (s Similar) Exec(n *uast.Node) error {
+	return s.ExecFiles([]*File{{UAST: n}})
+}
+
+func (s Similar) ExecFiles(files []*File) error {
+	found := false
+	for _, file := range files {
+		for _, f := range Functions(file.UAST) {
+			if f.Name != s.Function {
+				continue
+			}
+			found = true
+
+			start, _ := lineRange(f.Node)
+			fmt.Printf("File:%s, FuncName:%s, Line:%d\n", file.Path, f.Name, start)
+			printed := 0
+			for _, similar := range s.Index.Query(f, s.Threshold, 0) {
+				if similar.File == file.Path && similar.Name == f.Name && similar.Line == int(start) {
+					continue
+				}
+				if s.Limit > 0 && printed == s.Limit {
+					break
+				}
+				fmt.Print("\t", similar)
+				printed++
+			}
+		}
+	}
+	if !found {
+		return ErrFunctionNotFound.New(s.Function)
+	}
+	return nil
+}
+
+func (sf *SimilarFunction) String() string {
+	return fmt.Sprintf("File:%s, FuncName:%s, Line:%d, Jaccard:%.2f\n", sf.File, sf.Name, sf.Line, sf.Jaccard)
+}
+
+// NewSimilarityIndex returns an empty index with MinHash signatures of
+// bands * rows values.
+func NewSimilarityIndex(bands, rows int) (*SimilarityIndex, error) {
+	if bands < 1 || rows < 1 {
+		return nil, ErrInvalidLSHBands.New(bands, rows)
+	}
+	return &SimilarityIndex{Bands: bands, Rows: rows, buckets: make(map[uint64][]int)}, nil
+}
+
+// LoadSimilarityIndex reads an index saved with Save.
+func LoadSimilarityIndex(path string) (*SimilarityIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := &SimilarityIndex{}
+	if err := gob.NewDecoder(f).Decode(index); err != nil {
+		return nil, err
+	}
+	if index.Bands < 1 || index.Rows < 1 {
+		return nil, ErrInvalidLSHBands.New(index.Bands, index.Rows)
+	}
+	index.rebuild()
+	return index, nil
+}
+
+// Save writes the index to a file.
+func (si *SimilarityIndex) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(si); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Add indexes a function of a file.
+func (si *SimilarityIndex) Add(file string, f *Function) {
+	start, _ := lineRange(f.Node)
+	si.Functions = append(si.Functions, &IndexedFunction{
+		File:      file,
+		Name:      f.Name,
+		Line:      int(start),
+		Signature: MinHash(FunctionShingles(f), si.Bands*si.Rows),
+	})
+	si.addBuckets(len(si.Functions) - 1)
+}
+
+// Remove drops the functions of a file from the index.
+func (si *SimilarityIndex) Remove(file string) {
+	kept := si.Functions[:0]
+	for _, f := range si.Functions {
+		if f.File != file {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) != len(si.Functions) {
+		si.Functions = kept
+		si.rebuild()
+	}
+}
+
+// Query returns the indexed functions sharing a band with the function,
+// with an estimated Jaccard similarity of at least threshold, the most
+// similar first. Limit is the maximum number of results, zero for all.
+func (si *SimilarityIndex) Query(f *Function, threshold float64, limit int) []*SimilarFunction {
+	signature := MinHash(FunctionShingles(f), si.Bands*si.Rows)
+	candidates := make(map[int]bool)
+	for band := 0; band < si.Bands; band++ {
+		for _, i := range si.buckets[si.bucket(signature, band)] {
+			candidates[i] = true
+		}
+	}
+
+	var result []*SimilarFunction
+	for i := range candidates {
+		indexed := si.Functions[i]
+		equal := 0
+		for j, value := range signature {
+			if indexed.Signature[j] == value {
+				equal++
+			}
+		}
+		jaccard := float64(equal) / float64(len(signature))
+		if jaccard >= threshold {
+			result = append(result, &SimilarFunction{IndexedFunction: indexed, Jaccard: jaccard})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Jaccard != b.Jaccard {
+			return a.Jaccard > b.Jaccard
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (si *SimilarityIndex) rebuild() {
+	si.buckets = make(map[uint64][]int)
+	for i := range si.Functions {
+		si.addBuckets(i)
+	}
+}
+
+func (si *SimilarityIndex) addBuckets(i int) {
+	for band := 0; band < si.Bands; band++ {
+		key := si.bucket(si.Functions[i].Signature, band)
+		si.buckets[key] = append(si.buckets[key], i)
+	}
+}
+
+// bucket returns the hash of a band of a signature, along with the band.
+func (si *SimilarityIndex) bucket(signature []uint64, band int) uint64 {
+	digest := fnv.New64a()
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(band))
+	digest.Write(buf)
+	for _, value := range signature[band*si.Rows : (band+1)*si.Rows] {
+		binary.LittleEndian.PutUint64(buf, value)
+		digest.Write(buf)
+	}
+	return digest.Sum64()
+}
+
+// FunctionShingles returns the hashes of the features of a function used to
+// compare it with others:
+// * the internal type of every node along with the ones of its children
+// * the roles of every node along with the ones of its parent and
+//   grandparent
+// * the normalized tokens: the lower case parts of identifiers and the
+//   text of the rest, but literals which are ignored
+func FunctionShingles(f *Function) map[uint64]bool {
+	shingles := make(map[uint64]bool)
+	add := func(kind string, parts ...string) {
+		digest := fnv.New64a()
+		digest.Write([]byte(kind))
+		for _, part := range parts {
+			digest.Write([]byte{0})
+			digest.Write([]byte(part))
+		}
+		shingles[digest.Sum64()] = true
+	}
+
+	splitter := &IdentifierSplitter{Lowercase: true}
+	var visit func(n *uast.Node, ancestors []string)
+	visit = func(n *uast.Node, ancestors []string) {
+		if containsRoles(n, []uast.Role{uast.Comment}, nil) {
+			return
+		}
+
+		types := []string{n.InternalType}
+		for _, child := range n.Children {
+			types = append(types, child.InternalType)
+		}
+		add("type", types...)
+
+		roles := strings.Join(roleNames(n.Roles), "|")
+		add("roles", append(ancestors, roles)...)
+
+		switch {
+		case n.Token == "":
+		case containsRoles(n, []uast.Role{uast.Identifier}, nil):
+			for _, part := range splitter.Split(n.Token) {
+				add("token", part)
+			}
+		case !containsRoles(n, []uast.Role{uast.Literal}, nil):
+			add("token", n.Token)
+		}
+
+		if len(ancestors) == 2 {
+			ancestors = ancestors[1:]
+		}
+		ancestors = append(ancestors[:len(ancestors):len(ancestors)], roles)
+		for _, child := range n.Children {
+			visit(child, ancestors)
+		}
+	}
+	visit(f.Node, nil)
+	return shingles
+}
+
+// MinHash returns the MinHash signature of size values of a set of hashes,
+// the minimum of every one of size hash functions over the set.
+func MinHash(shingles map[uint64]bool, size int) []uint64 {
+	signature := make([]uint64, size)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for shingle := range shingles {
+		for i := range signature {
+			if h := mix64(shingle ^ minHashSeed(i)); h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+	return signature
+}
+
+// minHashSeed returns the seed of the i-th hash function of MinHash, which
+// must be stable so signatures can be compared across runs.
+func minHashSeed(i int) uint64 {
+	return mix64(uint64(i+1) * 0x9e3779b97f4a7c15)
+}
+
+// mix64 is the finalizer of SplitMix64, a bijective hash of 64 bits.
+func mix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestMinHash(t *testing.T) {
+	require := require.New(t)
+
+	a := make(map[uint64]bool)
+	b := make(map[uint64]bool)
+	for i := uint64(0); i < 300; i++ {
+		a[i] = true
+		b[i+100] = true
+	}
+	// |a ∩ b| = 200 and |a ∪ b| = 400
+	sa, sb := MinHash(a, 512), MinHash(b, 512)
+	require.Len(sa, 512)
+	require.Equal(sa, MinHash(a, 512))
+
+	equal := 0
+	for i := range sa {
+		if sa[i] == sb[i] {
+			equal++
+		}
+	}
+	require.True(math.Abs(float64(equal)/512-0.5) < 0.1)
+}
+
+func TestSimilarityIndex(t *testing.T) {
+	require := require.New(t)
+
+	getter := ckClassNode("A",
+		ckMethod("getName", &uast.Node{InternalType: "Return", Roles: []uast.Role{uast.Return}, Children: []*uast.Node{ckIdent("name")}}),
+		ckMethod("getSize", &uast.Node{InternalType: "Return", Roles: []uast.Role{uast.Return}, Children: []*uast.Node{ckIdent("size")}}),
+		ckMethod("run", ckCall("start"), ckCall("wait"), ckCall("stop")),
+	)
+	file := &uast.Node{InternalType: "File", Children: []*uast.Node{getter}}
+	funcs := Functions(file)
+	require.Len(funcs, 3)
+
+	index, err := NewSimilarityIndex(32, 4)
+	require.NoError(err)
+	for _, f := range funcs {
+		index.Add("A.java", f)
+	}
+
+	result := index.Query(funcs[0], 0, 0)
+	require.True(len(result) >= 2)
+	require.Equal("getName", result[0].Name)
+	require.Equal(1.0, result[0].Jaccard)
+	require.Equal("getSize", result[1].Name)
+	require.True(result[1].Jaccard > 0.3)
+	for _, r := range result[2:] {
+		require.True(r.Jaccard < result[1].Jaccard)
+	}
+
+	require.Len(index.Query(funcs[0], 0.99, 0), 1)
+	require.Len(index.Query(funcs[0], 0, 1), 1)
+
+	dir, err := ioutil.TempDir("", "minhash")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index")
+	require.NoError(index.Save(path))
+
+	loaded, err := LoadSimilarityIndex(path)
+	require.NoError(err)
+	require.Equal(index.Functions, loaded.Functions)
+	require.Equal(index.Query(funcs[2], 0, 0), loaded.Query(funcs[2], 0, 0))
+
+	loaded.Remove("A.java")
+	require.Empty(loaded.Functions)
+	require.Empty(loaded.Query(funcs[0], 0, 0))
+
+	_, err = NewSimilarityIndex(0, 4)
+	require.True(ErrInvalidLSHBands.Is(err))
+}
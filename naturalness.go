@@ -0,0 +1,340 @@
+package tools
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var ErrInvalidModelOrder = errors.NewKind("invalid n-gram model order: %d")
+
+// Naturalness scores the analyzed files and their functions by the
+// cross-entropy of their tokens under a token n-gram language model, as in
+// Hindle et al. "On the Naturalness of Software":
+// https://doi.org/10.1109/ICSE.2012.6227135
+//
+// Higher values mean less predictable, "unnatural" code, which is more
+// likely to be buggy (Ray et al. "On the "Naturalness" of Buggy Code":
+// https://doi.org/10.1145/2884781.2884848).
+//
+// If no Model is given, every file is scored with a model trained on the
+// rest of the analyzed files (leave-one-out). Functions are scored as token
+// streams of their own, with the same model as their file.
+type Naturalness struct {
+	// Order is the n of the n-grams of the trained models.
+	Order int
+	// Model is a model trained on a reference corpus, if any.
+	Model *NGramModel
+	// SaveModel, if not empty, is the file where a model trained on all the
+	// analyzed files is saved.
+	SaveModel string
+}
+
+// NaturalnessOptions are the options of Naturalness.
+type NaturalnessOptions struct {
+	Order     int    `long:"order" description:"n of the n-grams of the trained models" default:"3"`
+	Model     string `long:"model" description:"model trained on a reference corpus, instead of leave-one-out over the files"`
+	SaveModel string `long:"save-model" description:"file where a model trained on all the files is saved"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "naturalness",
+		Description: "Run n-gram language model cross-entropy calculation",
+		Options:     func() interface{} { return &NaturalnessOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*NaturalnessOptions)
+			naturalness := Naturalness{Order: o.Order, SaveModel: o.SaveModel}
+			if o.Model != "" {
+				model, err := LoadNGramModel(o.Model)
+				if err != nil {
+					return nil, err
+				}
+				naturalness.Model = model
+			}
+			return naturalness, nil
+		},
+	})
+}
+
+// NaturalnessScore is the cross-entropy of a file or a function.
+type NaturalnessScore struct {
+	File string
+	// Function is the name of the scored function, empty for whole files.
+	Function string
+	// CrossEntropy is the average negative log2 probability of the tokens.
+	CrossEntropy float64
+	Tokens       int
+}
+
+func (nat Naturalness) Exec(n *uast.Node) error {
+	return nat.ExecFiles([]*File{{UAST: n}})
+}
+
+func (nat Naturalness) ExecFiles(files []*File) error {
+	scores, err := nat.Score(files)
+	if err != nil {
+		return err
+	}
+	for _, score := range scores {
+		if score.Function != "" {
+			fmt.Print("\t")
+		}
+		fmt.Print(score)
+	}
+
+	if nat.SaveModel == "" {
+		return nil
+	}
+	model, err := NewNGramModel(nat.Order)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		model.Add(Tokens(file.UAST))
+	}
+	return model.Save(nat.SaveModel)
+}
+
+func (ns *NaturalnessScore) String() string {
+	if ns.Function != "" {
+		return fmt.Sprintf("FuncName:%s, CrossEntropy:%.4f, Tokens:%d\n", ns.Function, ns.CrossEntropy, ns.Tokens)
+	}
+	return fmt.Sprintf("File:%s, CrossEntropy:%.4f, Tokens:%d\n", ns.File, ns.CrossEntropy, ns.Tokens)
+}
+
+// Score returns the score of every file followed by the ones of its
+// functions.
+func (nat Naturalness) Score(files []*File) ([]*NaturalnessScore, error) {
+	streams := make([][]string, len(files))
+	for i, file := range files {
+		streams[i] = Tokens(file.UAST)
+	}
+
+	model := nat.Model
+	if model == nil {
+		var err error
+		if model, err = NewNGramModel(nat.Order); err != nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			model.Add(stream)
+		}
+	}
+
+	var scores []*NaturalnessScore
+	for i, file := range files {
+		if nat.Model == nil {
+			model.Remove(streams[i])
+		}
+
+		entropy, tokens := model.CrossEntropy(streams[i])
+		scores = append(scores, &NaturalnessScore{File: file.Path, CrossEntropy: entropy, Tokens: tokens})
+		for _, f := range Functions(file.UAST) {
+			entropy, tokens := model.CrossEntropy(Tokens(f.Node))
+			scores = append(scores, &NaturalnessScore{File: file.Path, Function: f.Name, CrossEntropy: entropy, Tokens: tokens})
+		}
+
+		if nat.Model == nil {
+			model.Add(streams[i])
+		}
+	}
+	return scores, nil
+}
+
+const (
+	ngramStart     = "<s>"
+	ngramSeparator = "\x00"
+	// kneserNeyDiscount is the usual absolute discount of Kneser-Ney
+	// smoothing.
+	kneserNeyDiscount = 0.75
+)
+
+// NGramModel is a token n-gram language model with interpolated Kneser-Ney
+// smoothing, as described in Chen & Goodman "An Empirical Study of
+// Smoothing Techniques for Language Modeling":
+// https://dash.harvard.edu/handle/1/25104739
+//
+// Every token stream is padded with Order-1 start tokens. Tokens never seen
+// in training get a share of the probability mass discounted from the
+// unigrams.
+type NGramModel struct {
+	Order    int
+	Discount float64
+	// Counts are the counts of the n-grams of the training streams, with
+	// their tokens joined by NUL characters.
+	Counts map[string]int
+
+	// levels, totals and types are derived from Counts by index: levels[k]
+	// are the counts of the (k+1)-grams, raw ones for the highest order and
+	// continuation counts for the rest, and totals[k] and types[k] the sum
+	// and number of the counts of every (k+1)-gram context.
+	levels  []map[string]int
+	totals  []map[string]int
+	types   []map[string]int
+	words   int
+	indexed bool
+}
+
+// NewNGramModel returns an empty model of the given order.
+func NewNGramModel(order int) (*NGramModel, error) {
+	if order < 1 {
+		return nil, ErrInvalidModelOrder.New(order)
+	}
+	return &NGramModel{Order: order, Discount: kneserNeyDiscount, Counts: make(map[string]int)}, nil
+}
+
+// LoadNGramModel reads a model saved with Save.
+func LoadNGramModel(path string) (*NGramModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	model := &NGramModel{}
+	if err := gob.NewDecoder(f).Decode(model); err != nil {
+		return nil, err
+	}
+	if model.Order < 1 {
+		return nil, ErrInvalidModelOrder.New(model.Order)
+	}
+	if model.Counts == nil {
+		model.Counts = make(map[string]int)
+	}
+	return model, nil
+}
+
+// Save writes the model to a file.
+func (m *NGramModel) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Add trains the model with a token stream.
+func (m *NGramModel) Add(tokens []string) {
+	m.update(tokens, 1)
+}
+
+// Remove undoes the training of the model with a token stream.
+func (m *NGramModel) Remove(tokens []string) {
+	m.update(tokens, -1)
+}
+
+func (m *NGramModel) update(tokens []string, delta int) {
+	padded := m.pad(tokens)
+	for i := m.Order - 1; i < len(padded); i++ {
+		key := strings.Join(padded[i-m.Order+1:i+1], ngramSeparator)
+		m.Counts[key] += delta
+		if m.Counts[key] <= 0 {
+			delete(m.Counts, key)
+		}
+	}
+	m.indexed = false
+}
+
+func (m *NGramModel) pad(tokens []string) []string {
+	padded := make([]string, 0, m.Order-1+len(tokens))
+	for i := 1; i < m.Order; i++ {
+		padded = append(padded, ngramStart)
+	}
+	return append(padded, tokens...)
+}
+
+// CrossEntropy returns the average negative log2 probability of the tokens
+// of a stream, and the number of tokens.
+func (m *NGramModel) CrossEntropy(tokens []string) (float64, int) {
+	if len(tokens) == 0 {
+		return 0, 0
+	}
+	m.index()
+
+	padded := m.pad(tokens)
+	var sum float64
+	for i := m.Order - 1; i < len(padded); i++ {
+		sum -= math.Log2(m.probability(padded[i-m.Order+1:i], padded[i]))
+	}
+	return sum / float64(len(tokens)), len(tokens)
+}
+
+// Probability returns the probability of a token following a context, of
+// which only the last Order-1 tokens are used.
+func (m *NGramModel) Probability(context []string, token string) float64 {
+	m.index()
+	if len(context) > m.Order-1 {
+		context = context[len(context)-m.Order+1:]
+	}
+	return m.probability(context, token)
+}
+
+func (m *NGramModel) probability(context []string, token string) float64 {
+	// unseen tokens are one more word of the vocabulary
+	lower := 1 / float64(m.words+1)
+	for k := 0; k <= len(context); k++ {
+		ctx := strings.Join(context[len(context)-k:], ngramSeparator)
+		total := m.totals[k][ctx]
+		if total == 0 {
+			continue
+		}
+
+		key := token
+		if k > 0 {
+			key = ctx + ngramSeparator + token
+		}
+		discounted := math.Max(float64(m.levels[k][key])-m.Discount, 0)
+		lower = (discounted + m.Discount*float64(m.types[k][ctx])*lower) / float64(total)
+	}
+	return lower
+}
+
+// index derives the counts of every order from the n-gram counts.
+func (m *NGramModel) index() {
+	if m.indexed {
+		return
+	}
+
+	m.levels = make([]map[string]int, m.Order)
+	m.totals = make([]map[string]int, m.Order)
+	m.types = make([]map[string]int, m.Order)
+	for k := range m.levels {
+		m.levels[k] = make(map[string]int)
+		m.totals[k] = make(map[string]int)
+		m.types[k] = make(map[string]int)
+	}
+	for key, count := range m.Counts {
+		m.levels[m.Order-1][key] = count
+	}
+	// the continuation count of a k-gram is the number of (k+1)-grams it
+	// is the suffix of
+	for k := m.Order - 1; k > 0; k-- {
+		for key := range m.levels[k] {
+			suffix := key[strings.Index(key, ngramSeparator)+1:]
+			m.levels[k-1][suffix]++
+		}
+	}
+
+	for k, level := range m.levels {
+		for key, count := range level {
+			ctx := ""
+			if k > 0 {
+				ctx = key[:strings.LastIndex(key, ngramSeparator)]
+			}
+			m.totals[k][ctx] += count
+			m.types[k][ctx]++
+		}
+	}
+	m.words = len(m.levels[0])
+	m.indexed = true
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestNGramModelProbability(t *testing.T) {
+	require := require.New(t)
+
+	model, err := NewNGramModel(3)
+	require.NoError(err)
+	model.Add(strings.Fields("a = b + c ; a = b + d ;"))
+	model.Add(strings.Fields("x = b + c ;"))
+
+	vocabulary := []string{"a", "=", "b", "+", "c", "d", ";", "x", "<unknown>"}
+	for _, context := range [][]string{nil, {"b"}, {"=", "b"}, {"x", "y"}, {"q", "r"}} {
+		var sum float64
+		for _, token := range vocabulary {
+			sum += model.Probability(context, token)
+		}
+		require.InDelta(1, sum, 1e-9, "%v", context)
+	}
+	require.True(model.Probability([]string{"b", "+"}, "c") > model.Probability([]string{"b", "+"}, "d"))
+
+	model.Remove(strings.Fields("x = b + c ;"))
+	require.Equal(model.Probability(nil, "x"), model.Probability(nil, "<unknown>"))
+
+	_, err = NewNGramModel(0)
+	require.True(ErrInvalidModelOrder.Is(err))
+}
+
+func TestNGramModelSave(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "naturalness")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	model, err := NewNGramModel(2)
+	require.NoError(err)
+	model.Add(strings.Fields("a = b ;"))
+	path := filepath.Join(dir, "model.gob")
+	require.NoError(model.Save(path))
+
+	loaded, err := LoadNGramModel(path)
+	require.NoError(err)
+	require.Equal(2, loaded.Order)
+	require.Equal(model.Counts, loaded.Counts)
+	require.Equal(model.Probability([]string{"a"}, "="), loaded.Probability([]string{"a"}, "="))
+}
+
+func naturalnessFile(path string, code string) *File {
+	n := &uast.Node{InternalType: "File"}
+	for _, line := range strings.Split(code, "\n") {
+		f := &uast.Node{InternalType: "Method", Roles: []uast.Role{uast.Function, uast.Declaration}}
+		for _, token := range strings.Fields(line) {
+			f.Children = append(f.Children, &uast.Node{InternalType: "Token", Token: token})
+		}
+		f.Children[0].Roles = []uast.Role{uast.Function, uast.Name, uast.Identifier}
+		n.Children = append(n.Children, f)
+	}
+	return &File{Path: path, UAST: n}
+}
+
+func TestNaturalness(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{
+		naturalnessFile("a.java", "get return this . name ;\nset this . name = name ;"),
+		naturalnessFile("b.java", "get return this . id ;\nset this . id = id ;"),
+		naturalnessFile("c.java", "get return this . size ;\nset this . size = size ;"),
+		naturalnessFile("d.java", "weird while ( ! ! x ) { } }"),
+	}
+	scores, err := Naturalness{Order: 3}.Score(files)
+	require.NoError(err)
+	require.Len(scores, 11)
+
+	require.Equal(&NaturalnessScore{File: "a.java", CrossEntropy: scores[0].CrossEntropy, Tokens: 13}, scores[0])
+	require.Equal("get", scores[1].Function)
+	require.Equal(6, scores[1].Tokens)
+	require.Equal("d.java", scores[9].File)
+	for i := 0; i < 9; i += 3 {
+		require.True(scores[i].CrossEntropy < scores[9].CrossEntropy)
+	}
+
+	model, err := NewNGramModel(3)
+	require.NoError(err)
+	for _, file := range files[:3] {
+		model.Add(Tokens(file.UAST))
+	}
+	scores, err = Naturalness{Model: model}.Score(files[:1])
+	require.NoError(err)
+	entropy, _ := model.CrossEntropy(Tokens(files[0].UAST))
+	require.Equal(entropy, scores[0].CrossEntropy)
+}
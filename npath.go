@@ -8,6 +8,14 @@ import (
 
 type NPath struct{}
 
+func init() {
+	Register(&ToolInfo{
+		Name:        "npath",
+		Description: "Run npath complexity calculation",
+		New:         func(*ToolEnv) (Tooler, error) { return NPath{}, nil },
+	})
+}
+
 type NPathData struct {
 	Name       string
 	Complexity int
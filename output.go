@@ -0,0 +1,18 @@
+package tools
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrUnknownFormat is returned by the tools when asked for an output format
+// they don't support.
+var ErrUnknownFormat = errors.NewKind("unknown output format: %s")
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote returns s as a quoted Graphviz DOT identifier.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Paths extracts the leaf-to-leaf path contexts of every function, the
+// input of code2vec (https://code2vec.org) and code2seq
+// (https://code2seq.org) models.
+//
+// A path context is made of the tokens of two leaves of the function and the
+// path between them: the labels of the nodes going up from the first leaf to
+// their lowest common ancestor and then down to the second, such as
+// (Name)^(Call)_(Name). Labels are the internal types of the nodes, or their
+// roles if Roles is set, which are the same for every language.
+//
+// Functions are printed one per line in the code2vec text format: the name
+// of the function and its path contexts, separated by spaces. Tokens and
+// names are split into lower case parts joined by `|`, and the name of the
+// function is replaced by METHOD_NAME in its tokens.
+type Paths struct {
+	// MaxLength is the maximum number of edges of a path, 8 if zero.
+	MaxLength int
+	// MaxWidth is the maximum distance between the children of the lowest
+	// common ancestor a path goes through, 2 if zero.
+	MaxWidth int
+	// Hash replaces the paths by their Java String.hashCode, as done by
+	// code2vec.
+	Hash bool
+	// Roles labels the nodes by their roles instead of their internal types.
+	Roles bool
+}
+
+// PathsOptions are the options of Paths.
+type PathsOptions struct {
+	MaxLength int  `long:"max-length" description:"maximum number of edges of a path" default:"8"`
+	MaxWidth  int  `long:"max-width" description:"maximum distance between the children of the common ancestor of a path" default:"2"`
+	Hash      bool `long:"hash" description:"replace the paths by their Java hash code, as code2vec does"`
+	Roles     bool `long:"roles" description:"label the nodes of the paths by their roles instead of their internal types"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "paths",
+		Description: "Run code2vec path context extraction",
+		Options:     func() interface{} { return &PathsOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*PathsOptions)
+			return Paths{MaxLength: o.MaxLength, MaxWidth: o.MaxWidth, Hash: o.Hash, Roles: o.Roles}, nil
+		},
+	})
+}
+
+// FunctionPaths are the path contexts of a function.
+type FunctionPaths struct {
+	// Name is the normalized name of the function.
+	Name     string
+	Contexts []*PathContext
+}
+
+// PathContext is a path between two leaves and their tokens.
+type PathContext struct {
+	Start string
+	Path  string
+	End   string
+}
+
+const (
+	pathMethodName = "METHOD_NAME"
+	pathBlank      = "BLANK"
+)
+
+func (p Paths) Exec(n *uast.Node) error {
+	for _, fp := range p.Extract(n) {
+		fmt.Print(fp)
+	}
+	return nil
+}
+
+func (fp *FunctionPaths) String() string {
+	var line strings.Builder
+	line.WriteString(fp.Name)
+	for _, ctx := range fp.Contexts {
+		line.WriteString(" ")
+		line.WriteString(ctx.String())
+	}
+	line.WriteString("\n")
+	return line.String()
+}
+
+func (pc *PathContext) String() string {
+	return pc.Start + "," + pc.Path + "," + pc.End
+}
+
+// Extract returns the path contexts of every named function contained in
+// the node, skipping the ones without any.
+func (p Paths) Extract(n *uast.Node) []*FunctionPaths {
+	var result []*FunctionPaths
+	for _, f := range Functions(n) {
+		if f.Name == "" {
+			continue
+		}
+		fp := &FunctionPaths{Name: pathToken(f.Name), Contexts: p.contexts(f)}
+		if len(fp.Contexts) > 0 {
+			result = append(result, fp)
+		}
+	}
+	return result
+}
+
+// pathLeaf is a leaf of a function along with the nodes from the function
+// to it, and the index of each of them in the children of its parent.
+type pathLeaf struct {
+	token   string
+	nodes   []*uast.Node
+	indices []int
+}
+
+func (p Paths) contexts(f *Function) []*PathContext {
+	var leaves []*pathLeaf
+	var collect func(n *uast.Node, nodes []*uast.Node, indices []int)
+	collect = func(n *uast.Node, nodes []*uast.Node, indices []int) {
+		nodes = append(nodes[:len(nodes):len(nodes)], n)
+		if len(n.Children) == 0 {
+			if n.Token != "" {
+				token := pathToken(n.Token)
+				if n.Token == f.Name {
+					token = pathMethodName
+				}
+				leaves = append(leaves, &pathLeaf{token: token, nodes: nodes, indices: indices})
+			}
+			return
+		}
+		// comments are skipped, and don't count for the width of paths
+		i := 0
+		for _, child := range n.Children {
+			if containsRoles(child, []uast.Role{uast.Comment}, nil) {
+				continue
+			}
+			collect(child, nodes, append(indices[:len(indices):len(indices)], i))
+			i++
+		}
+	}
+	collect(f.Node, nil, nil)
+
+	var contexts []*PathContext
+	for i, a := range leaves {
+		for _, b := range leaves[i+1:] {
+			path, ok := p.path(a, b)
+			if !ok {
+				continue
+			}
+			contexts = append(contexts, &PathContext{Start: a.token, Path: path, End: b.token})
+		}
+	}
+	return contexts
+}
+
+// path returns the path between two leaves, and false if it's too long or
+// too wide.
+func (p Paths) path(a, b *pathLeaf) (string, bool) {
+	common := 0
+	for common < len(a.indices) && common < len(b.indices) && a.indices[common] == b.indices[common] {
+		common++
+	}
+	// a leaf can't be the ancestor of another one
+	if common == len(a.indices) || common == len(b.indices) {
+		return "", false
+	}
+
+	length := len(a.indices) - common + len(b.indices) - common
+	width := b.indices[common] - a.indices[common]
+	if width < 0 {
+		width = -width
+	}
+	maxLength, maxWidth := p.MaxLength, p.MaxWidth
+	if maxLength <= 0 {
+		maxLength = 8
+	}
+	if maxWidth <= 0 {
+		maxWidth = 2
+	}
+	if length > maxLength || width > maxWidth {
+		return "", false
+	}
+
+	var path strings.Builder
+	for i := len(a.nodes) - 1; i >= common; i-- {
+		path.WriteString("(" + p.label(a.nodes[i]) + ")")
+		if i > common {
+			path.WriteString("^")
+		}
+	}
+	for _, n := range b.nodes[common+1:] {
+		path.WriteString("_(" + p.label(n) + ")")
+	}
+
+	if p.Hash {
+		return strconv.Itoa(int(javaHashCode(path.String()))), true
+	}
+	return path.String(), true
+}
+
+func (p Paths) label(n *uast.Node) string {
+	if p.Roles {
+		return strings.Join(roleNames(n.Roles), "|")
+	}
+	return n.InternalType
+}
+
+// pathToken normalizes a token as done by code2seq: its lower case parts
+// joined by `|`, or its text without the separators of the format if it has
+// no letters or digits.
+func pathToken(token string) string {
+	splitter := &IdentifierSplitter{Lowercase: true}
+	if parts := splitter.Split(token); len(parts) > 0 {
+		return strings.Join(parts, "|")
+	}
+	token = strings.Join(strings.FieldsFunc(token, func(r rune) bool {
+		return r == ',' || r == '|' || strings.ContainsRune(" \t\r\n", r)
+	}), "")
+	if token == "" {
+		return pathBlank
+	}
+	return token
+}
+
+// javaHashCode returns the hash of a string as computed by Java's
+// String.hashCode.
+func javaHashCode(s string) int32 {
+	var h int32
+	for _, c := range utf16.Encode([]rune(s)) {
+		h = 31*h + int32(c)
+	}
+	return h
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func pathsFixture() *uast.Node {
+	// int getSize() { return this.size + 1; }
+	return &uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "Method", Roles: []uast.Role{uast.Function, uast.Declaration}, Children: []*uast.Node{
+			{InternalType: "Type", Roles: []uast.Role{uast.Type}, Token: "int"},
+			{InternalType: "Name", Roles: []uast.Role{uast.Function, uast.Name, uast.Identifier}, Token: "getSize"},
+			{InternalType: "Return", Roles: []uast.Role{uast.Return}, Children: []*uast.Node{
+				{InternalType: "Plus", Roles: []uast.Role{uast.Binary, uast.Add}, Children: []*uast.Node{
+					{InternalType: "Field", Roles: []uast.Role{uast.Identifier}, Token: "this.size"},
+					{InternalType: "Comment", Roles: []uast.Role{uast.Comment}, Token: "// plus"},
+					{InternalType: "Number", Roles: []uast.Role{uast.Literal}, Token: "1"},
+				}},
+			}},
+		}},
+	}}
+}
+
+func TestPaths(t *testing.T) {
+	require := require.New(t)
+
+	n := pathsFixture()
+	result := Paths{MaxLength: 8, MaxWidth: 2}.Extract(n)
+	require.Len(result, 1)
+	require.Equal("get|size", result[0].Name)
+
+	var contexts []string
+	for _, ctx := range result[0].Contexts {
+		contexts = append(contexts, ctx.String())
+	}
+	require.Equal([]string{
+		"int,(Type)^(Method)_(Name),METHOD_NAME",
+		"int,(Type)^(Method)_(Return)_(Plus)_(Field),this|size",
+		"int,(Type)^(Method)_(Return)_(Plus)_(Number),1",
+		"METHOD_NAME,(Name)^(Method)_(Return)_(Plus)_(Field),this|size",
+		"METHOD_NAME,(Name)^(Method)_(Return)_(Plus)_(Number),1",
+		"this|size,(Field)^(Plus)_(Number),1",
+	}, contexts)
+
+	// zero limits are the default ones
+	require.Equal(result, Paths{}.Extract(n))
+
+	result = Paths{MaxLength: 4, MaxWidth: 1}.Extract(n)
+	contexts = nil
+	for _, ctx := range result[0].Contexts {
+		contexts = append(contexts, ctx.String())
+	}
+	require.Equal([]string{
+		"int,(Type)^(Method)_(Name),METHOD_NAME",
+		"METHOD_NAME,(Name)^(Method)_(Return)_(Plus)_(Field),this|size",
+		"METHOD_NAME,(Name)^(Method)_(Return)_(Plus)_(Number),1",
+		"this|size,(Field)^(Plus)_(Number),1",
+	}, contexts)
+
+	result = Paths{MaxLength: 2, MaxWidth: 2, Roles: true}.Extract(n)
+	require.Equal([]*PathContext{{
+		Start: "int",
+		Path:  "(Type)^(Function|Declaration)_(Function|Name|Identifier)",
+		End:   "METHOD_NAME",
+	}, {
+		Start: "this|size",
+		Path:  "(Identifier)^(Binary|Add)_(Literal)",
+		End:   "1",
+	}}, result[0].Contexts)
+
+	result = Paths{MaxLength: 2, MaxWidth: 2, Hash: true, Roles: true}.Extract(n)
+	hash := javaHashCode("(Type)^(Function|Declaration)_(Function|Name|Identifier)")
+	require.Equal(strconv.Itoa(int(hash)), result[0].Contexts[0].Path)
+}
+
+func TestJavaHashCode(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(int32(0), javaHashCode(""))
+	require.Equal(int32(99162322), javaHashCode("hello"))
+	require.Equal(int32(96354), javaHashCode("abc"))
+	require.Equal(int32(-2147483648), javaHashCode("polygenelubricants"))
+}
+
+func TestPathToken(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("get|user|name", pathToken("getUserName"))
+	require.Equal("+", pathToken("+"))
+	require.Equal("BLANK", pathToken(", "))
+}
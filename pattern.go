@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var (
+	ErrEmptyPattern = errors.NewKind("no UAST nodes found for the pattern: %s")
+	ErrInvalidWrap  = errors.NewKind("pattern wrap must contain %%s once: %s")
+)
+
+const metavariablePrefix = "__mv_"
+
+var metavariableRegexp = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Pattern is a snippet of code, such as `$X == null`, whose UAST is matched
+// against other UASTs. Metavariables, written as `$` followed by a name,
+// match any subtree, and every occurrence of the same one must match equal
+// subtrees.
+//
+// Patterns are parsed as any other code, so they must be valid code once
+// their metavariables are replaced by identifiers. Snippets which are not
+// valid on their own, like expressions in Java, can be wrapped by code
+// making them valid, such as `class A { void f() { %s; } }`.
+type Pattern struct {
+	// Text is the pattern as written.
+	Text string
+	// Root is the UAST node of the pattern.
+	Root *uast.Node
+}
+
+// PatternMatch is a node matching a Pattern and the subtrees bound to its
+// metavariables.
+type PatternMatch struct {
+	Node     *uast.Node
+	Bindings map[string]*uast.Node
+}
+
+// PatternSource returns the code to parse for a pattern: the pattern, with
+// its metavariables replaced by identifiers, inside wrap, a template with
+// one %s, if not empty. The pattern is found from the offset start to end of
+// the code.
+func PatternSource(pattern, wrap string) (source string, start, end int, err error) {
+	code := metavariableRegexp.ReplaceAllString(pattern, metavariablePrefix+"$1")
+	if wrap == "" {
+		return code, 0, len(code), nil
+	}
+	if strings.Count(wrap, "%s") != 1 {
+		return "", 0, 0, ErrInvalidWrap.New(wrap)
+	}
+	start = strings.Index(wrap, "%s")
+	return wrap[:start] + code + wrap[start+2:], start, start + len(code), nil
+}
+
+// NewPattern returns the pattern whose code, as returned by PatternSource,
+// was parsed into root. The node of the pattern is the deepest one covering
+// the nodes positioned from start to end: their lowest common ancestor,
+// skipping the wrappers with the same span as their only child, such as an
+// expression statement around an expression, so the pattern also matches
+// where the wrapper is missing. It is root if no node has a position.
+func NewPattern(text string, root *uast.Node, start, end int) (*Pattern, error) {
+	var lca []*uast.Node
+	found := false
+	var visit func(n *uast.Node, path []*uast.Node)
+	visit = func(n *uast.Node, path []*uast.Node) {
+		path = append(path[:len(path):len(path)], n)
+		if inRange(n, start, end) {
+			if !found {
+				lca, found = path, true
+			} else {
+				common := 0
+				for common < len(lca) && common < len(path) && lca[common] == path[common] {
+					common++
+				}
+				lca = lca[:common]
+			}
+		}
+		for _, child := range n.Children {
+			visit(child, path)
+		}
+	}
+	visit(root, nil)
+
+	if !found {
+		if startPosition(root) != nil {
+			return nil, ErrEmptyPattern.New(text)
+		}
+		return &Pattern{Text: text, Root: root}, nil
+	}
+	node := lca[len(lca)-1]
+	for len(node.Children) == 1 && sameSpan(node, node.Children[0]) {
+		node = node.Children[0]
+	}
+	return &Pattern{Text: text, Root: node}, nil
+}
+
+// sameSpan returns whether both nodes are positioned at the same offsets.
+func sameSpan(a, b *uast.Node) bool {
+	positioned := func(p *uast.Position) bool { return p != nil && p.Line != 0 }
+	if !positioned(a.StartPosition) || !positioned(b.StartPosition) || a.StartPosition.Offset != b.StartPosition.Offset {
+		return false
+	}
+	if !positioned(a.EndPosition) || !positioned(b.EndPosition) {
+		return true
+	}
+	return a.EndPosition.Offset == b.EndPosition.Offset
+}
+
+func inRange(n *uast.Node, start, end int) bool {
+	if n.StartPosition == nil || n.StartPosition.Line == 0 {
+		return false
+	}
+	if int(n.StartPosition.Offset) < start || int(n.StartPosition.Offset) >= end {
+		return false
+	}
+	return n.EndPosition == nil || n.EndPosition.Line == 0 || int(n.EndPosition.Offset) <= end
+}
+
+// Find returns the nodes contained in n, including itself, matching the
+// pattern, in preorder.
+func (p *Pattern) Find(n *uast.Node) []*PatternMatch {
+	var matches []*PatternMatch
+	var visit func(n *uast.Node)
+	visit = func(n *uast.Node) {
+		if bindings, ok := p.Match(n); ok {
+			matches = append(matches, &PatternMatch{Node: n, Bindings: bindings})
+		}
+		for _, child := range n.Children {
+			visit(child)
+		}
+	}
+	visit(n)
+	return matches
+}
+
+// Match returns whether the node matches the pattern, and the subtrees
+// bound to its metavariables, by name without the `$`.
+func (p *Pattern) Match(n *uast.Node) (map[string]*uast.Node, bool) {
+	bindings := make(map[string]*uast.Node)
+	if !matchPattern(p.Root, n, bindings) {
+		return nil, false
+	}
+	return bindings, true
+}
+
+// String returns the text of the bound subtrees, as $X=text, sorted by name.
+func (pm *PatternMatch) String() string {
+	names := make([]string, 0, len(pm.Bindings))
+	for name := range pm.Bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("$%s=%s", name, strings.Join(Tokens(pm.Bindings[name]), " ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matchPattern tells if n matches the pattern node p. Internal types, roles,
+// tokens, properties and children must be the same, but for metavariables,
+// which are bound to n or must match the subtree already bound. Comments are
+// ignored.
+func matchPattern(p, n *uast.Node, bindings map[string]*uast.Node) bool {
+	if strings.HasPrefix(p.Token, metavariablePrefix) {
+		name := strings.TrimPrefix(p.Token, metavariablePrefix)
+		if bound, ok := bindings[name]; ok {
+			return matchPattern(bound, n, nil)
+		}
+		if bindings != nil {
+			bindings[name] = n
+		}
+		return true
+	}
+
+	if p.InternalType != n.InternalType || p.Token != n.Token ||
+		!sameRoles(p.Roles, n.Roles) || !sameProperties(p.Properties, n.Properties) {
+		return false
+	}
+
+	pc, nc := uncommentedChildren(p), uncommentedChildren(n)
+	if len(pc) != len(nc) {
+		return false
+	}
+	for i := range pc {
+		if !matchPattern(pc[i], nc[i], bindings) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRoles(a, b []uast.Role) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[uast.Role]int)
+	for _, r := range a {
+		count[r]++
+	}
+	for _, r := range b {
+		count[r]--
+		if count[r] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sameProperties(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+	return true
+}
+
+func uncommentedChildren(n *uast.Node) []*uast.Node {
+	var children []*uast.Node
+	for _, child := range n.Children {
+		if !containsRoles(child, []uast.Role{uast.Comment}, nil) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
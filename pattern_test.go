@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func patternNode(typ, token string, start, end uint32, roles []uast.Role, children ...*uast.Node) *uast.Node {
+	n := &uast.Node{InternalType: typ, Token: token, Roles: roles, Children: children}
+	if end > 0 {
+		n.StartPosition = &uast.Position{Offset: start, Line: 1, Col: start + 1}
+		n.EndPosition = &uast.Position{Offset: end, Line: 1, Col: end + 1}
+	}
+	return n
+}
+
+func equalsNull(left *uast.Node) *uast.Node {
+	return patternNode("Infix", "==", 0, 0, []uast.Role{uast.Binary, uast.Equal},
+		left,
+		patternNode("Null", "null", 0, 0, []uast.Role{uast.Literal, uast.Null}),
+	)
+}
+
+func TestPatternSource(t *testing.T) {
+	require := require.New(t)
+
+	source, start, end, err := PatternSource("$X == null", "f(%s)")
+	require.NoError(err)
+	require.Equal("f(__mv_X == null)", source)
+	require.Equal("__mv_X == null", source[start:end])
+
+	source, start, end, err = PatternSource("$a_1 + $b", "")
+	require.NoError(err)
+	require.Equal("__mv_a_1 + __mv_b", source)
+	require.Equal(0, start)
+	require.Equal(len(source), end)
+
+	_, _, _, err = PatternSource("x", "f()")
+	require.True(ErrInvalidWrap.Is(err))
+}
+
+func TestPattern(t *testing.T) {
+	require := require.New(t)
+
+	// f(__mv_X == null)
+	root := patternNode("Call", "", 0, 17, []uast.Role{uast.Call},
+		patternNode("Name", "f", 0, 1, []uast.Role{uast.Call, uast.Callee}),
+		patternNode("Infix", "==", 2, 16, []uast.Role{uast.Binary, uast.Equal},
+			patternNode("Name", "__mv_X", 2, 8, []uast.Role{uast.Identifier}),
+			patternNode("Null", "null", 12, 16, []uast.Role{uast.Literal, uast.Null}),
+		),
+	)
+	pattern, err := NewPattern("$X == null", root, 2, 16)
+	require.NoError(err)
+	require.Equal(root.Children[1], pattern.Root)
+
+	call := patternNode("Call", "", 0, 0, []uast.Role{uast.Call},
+		patternNode("Name", "get", 0, 0, []uast.Role{uast.Call, uast.Callee}),
+	)
+	matched := equalsNull(call)
+	matched.StartPosition = &uast.Position{Offset: 40, Line: 3, Col: 5}
+	file := patternNode("File", "", 0, 0, nil,
+		equalsNull(patternNode("Name", "x", 0, 0, []uast.Role{uast.Identifier})),
+		&uast.Node{InternalType: "Comment", Roles: []uast.Role{uast.Comment}, Token: "// x"},
+		patternNode("Infix", "==", 0, 0, []uast.Role{uast.Binary, uast.Equal},
+			patternNode("Name", "a", 0, 0, []uast.Role{uast.Identifier}),
+			patternNode("Name", "b", 0, 0, []uast.Role{uast.Identifier}),
+		),
+		matched,
+	)
+
+	matches := pattern.Find(file)
+	require.Len(matches, 2)
+	require.Equal("$X=x", matches[0].String())
+	require.Equal(call, matches[1].Bindings["X"])
+
+	findings := Search{Pattern: pattern}.Find([]*File{{Path: "A.java", UAST: file}})
+	require.Len(findings, 2)
+	require.Equal("A.java:3:5: matches $X == null with $X=get [search]\n", findings[1].String())
+
+	// __mv_A + __mv_A
+	twice := &Pattern{Text: "$A + $A", Root: patternNode("Infix", "+", 0, 0, []uast.Role{uast.Binary, uast.Add},
+		patternNode("Name", "__mv_A", 0, 0, []uast.Role{uast.Identifier}),
+		patternNode("Name", "__mv_A", 0, 0, []uast.Role{uast.Identifier}),
+	)}
+	sum := func(a, b string) *uast.Node {
+		return patternNode("Infix", "+", 0, 0, []uast.Role{uast.Add, uast.Binary},
+			patternNode("Name", a, 0, 0, []uast.Role{uast.Identifier}),
+			patternNode("Name", b, 0, 0, []uast.Role{uast.Identifier}),
+		)
+	}
+	_, ok := twice.Match(sum("i", "i"))
+	require.True(ok)
+	_, ok = twice.Match(sum("i", "j"))
+	require.False(ok)
+
+	_, err = NewPattern("x", root, 30, 40)
+	require.True(ErrEmptyPattern.Is(err))
+	noPositions := sum("i", "j")
+	pattern, err = NewPattern("i + j", noPositions, 0, 5)
+	require.NoError(err)
+	require.Equal(noPositions, pattern.Root)
+}
+
+func TestPatternInCondition(t *testing.T) {
+	require := require.New(t)
+
+	// __mv_X == None, parsed as a module with an expression statement
+	root := patternNode("Module", "", 0, 14, []uast.Role{uast.File},
+		patternNode("Expr", "", 0, 14, []uast.Role{uast.Expression},
+			patternNode("Compare", "==", 0, 14, []uast.Role{uast.Binary, uast.Equal},
+				patternNode("Name", "__mv_X", 0, 6, []uast.Role{uast.Identifier}),
+				patternNode("NoneLiteral", "None", 10, 14, []uast.Role{uast.Literal, uast.Null}),
+			),
+		),
+	)
+	pattern, err := NewPattern("$X == None", root, 0, 14)
+	require.NoError(err)
+	require.Equal(root.Children[0].Children[0], pattern.Root)
+
+	// if y == None: pass
+	condition := patternNode("Compare", "==", 0, 0, []uast.Role{uast.Binary, uast.Equal},
+		patternNode("Name", "y", 0, 0, []uast.Role{uast.Identifier}),
+		patternNode("NoneLiteral", "None", 0, 0, []uast.Role{uast.Literal, uast.Null}),
+	)
+	file := patternNode("Module", "", 0, 0, []uast.Role{uast.File},
+		patternNode("If", "", 0, 0, []uast.Role{uast.If, uast.Statement},
+			condition,
+			patternNode("Pass", "pass", 0, 0, []uast.Role{uast.If, uast.Then, uast.Statement}),
+		),
+	)
+	matches := pattern.Find(file)
+	require.Len(matches, 1)
+	require.Equal(condition, matches[0].Node)
+	require.Equal("$X=y", matches[0].String())
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var ErrPlugin = errors.NewKind("cannot load plugin %s: %s")
+
+// PluginSymbol is the name of the symbol looked up in the plugins, a
+// function with the signature of PluginFunc.
+const PluginSymbol = "Plugin"
+
+// PluginFunc returns the tool provided by a plugin and its metadata.
+type PluginFunc = func() *Plugin
+
+// Plugin is a tool built out of this repository as a Go plugin, with
+// -buildmode=plugin, and loaded at run time. A plugin is a main package
+// exporting a PluginSymbol function, e.g.:
+//
+//   func Plugin() *tools.Plugin {
+//       return &tools.Plugin{
+//           Name:        "todo",
+//           Description: "Run TODO comments counter",
+//           Tool:        &Todo{},
+//       }
+//   }
+//
+// Plugins must be built with the same version of this package and its
+// dependencies as the program loading them.
+type Plugin struct {
+	// Name is the name of the command running the tool.
+	Name string
+	// Description is the short description of the command.
+	Description string
+	// Tool is the tool run, which may implement MultiTooler too. If it is a
+	// pointer to a struct, its fields tagged as in go-flags are options of
+	// the command, set before it is run.
+	Tool Tooler
+
+	// Path is the path of the shared object the plugin was loaded from.
+	Path string
+}
+
+// LoadPlugins loads the plugins of the .so files in a directory, sorted by
+// name. A plugin failing to load doesn't stop the others from loading: it
+// is left out and its error, of kind ErrPlugin, returned in failed.
+func LoadPlugins(dir string) (plugins []*Plugin, failed []error, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".so" {
+			continue
+		}
+		p, err := LoadPlugin(filepath.Join(dir, info.Name()))
+		if err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, failed, nil
+}
+
+// LoadPlugin loads the plugin of a shared object.
+func LoadPlugin(path string) (*Plugin, error) {
+	so, err := plugin.Open(path)
+	if err != nil {
+		return nil, ErrPlugin.New(path, err)
+	}
+
+	sym, err := so.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, ErrPlugin.New(path, err)
+	}
+	f, ok := sym.(PluginFunc)
+	if !ok {
+		return nil, ErrPlugin.New(path, "symbol Plugin is not a func() *tools.Plugin")
+	}
+
+	p := f()
+	switch {
+	case p == nil:
+		return nil, ErrPlugin.New(path, "no plugin returned")
+	case p.Name == "":
+		return nil, ErrPlugin.New(path, "no name")
+	case p.Tool == nil:
+		return nil, ErrPlugin.New(path, "no tool")
+	}
+	p.Path = path
+	return p, nil
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "plugins")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644))
+	plugins, failed, err := LoadPlugins(dir)
+	require.NoError(err)
+	require.Empty(plugins)
+	require.Empty(failed)
+
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "bad.so"), []byte("not a plugin"), 0644))
+	plugins, failed, err = LoadPlugins(dir)
+	require.NoError(err)
+	require.Empty(plugins)
+	require.Len(failed, 1)
+	require.True(ErrPlugin.Is(failed[0]))
+
+	_, _, err = LoadPlugins(filepath.Join(dir, "missing"))
+	require.Error(err)
+}
+
+const pluginSource = `package main
+
+import (
+	"github.com/bblfsh/tools"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+type Todo struct {
+	Format string ` + "`long:\"format\"`" + `
+}
+
+func (Todo) Exec(*uast.Node) error { return nil }
+
+func Plugin() *tools.Plugin {
+	return &tools.Plugin{Name: "todo", Description: "Run TODO comments counter", Tool: &Todo{}}
+}
+`
+
+func TestLoadPluginsBuilt(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("plugins not supported")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	require := require.New(t)
+
+	// the plugin is built inside the module to use the same dependencies
+	src, err := ioutil.TempDir(".", "_plugin")
+	require.NoError(err)
+	defer os.RemoveAll(src)
+	require.NoError(ioutil.WriteFile(filepath.Join(src, "main.go"), []byte(pluginSource), 0644))
+
+	dir, err := ioutil.TempDir("", "plugins")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "bad.so"), []byte("not a plugin"), 0644))
+
+	// plugins are rejected by the test binary, which has this package built
+	// with its tests, so the plugin is loaded by the CLI built alongside it
+	cli := filepath.Join(dir, "bblfsh-tools")
+	for _, args := range [][]string{
+		{"build", "-buildmode=plugin", "-o", filepath.Join(dir, "todo.so"), "./" + filepath.Base(src)},
+		{"build", "-o", cli, "./cmd/bblfsh-tools"},
+	} {
+		if out, err := exec.Command("go", args...).CombinedOutput(); err != nil {
+			t.Skipf("cannot build: %s\n%s", err, out)
+		}
+	}
+
+	cmd := exec.Command(cli, "list-tools")
+	cmd.Env = append(os.Environ(), "BBLFSH_TOOLS_PLUGINS="+dir)
+	out, err := cmd.CombinedOutput()
+	require.NoError(err, "%s", out)
+	require.Contains(string(out), "skipping plugin: cannot load plugin "+filepath.Join(dir, "bad.so"))
+	require.Regexp(`(?m)^todo +Run TODO comments counter\n +--format`, string(out))
+
+	cmd = exec.Command(cli, "todo", "--uast", "--format", "json", "fixtures/npath/ifelse.java.json")
+	cmd.Env = append(os.Environ(), "BBLFSH_TOOLS_PLUGINS="+dir)
+	out, err = cmd.CombinedOutput()
+	require.NoError(err, "%s", out)
+}
@@ -0,0 +1,62 @@
+package tools
+
+import "gopkg.in/bblfsh/sdk.v1/uast"
+
+// lineRange returns the first and last lines spanned by the node and its
+// children. Drivers don't set positions on every node, e.g. blocks, so
+// every child is looked at. Both are zero if no node has a position.
+func lineRange(n *uast.Node) (start, end uint32) {
+	for _, pos := range []*uast.Position{n.StartPosition, n.EndPosition} {
+		if pos == nil || pos.Line == 0 {
+			continue
+		}
+		if start == 0 || pos.Line < start {
+			start = pos.Line
+		}
+		if pos.Line > end {
+			end = pos.Line
+		}
+	}
+	for _, child := range n.Children {
+		s, e := lineRange(child)
+		if s != 0 && (start == 0 || s < start) {
+			start = s
+		}
+		if e > end {
+			end = e
+		}
+	}
+	return start, end
+}
+
+// startPosition returns the first position of the node and its children,
+// or nil if no node has one.
+func startPosition(n *uast.Node) *uast.Position {
+	first := n.StartPosition
+	if first != nil && first.Line == 0 {
+		first = nil
+	}
+	for _, child := range n.Children {
+		pos := startPosition(child)
+		if pos != nil && (first == nil || pos.Line < first.Line || pos.Line == first.Line && pos.Col < first.Col) {
+			first = pos
+		}
+	}
+	return first
+}
+
+// endPosition returns the last end position of the node and its children,
+// or nil if no node has one.
+func endPosition(n *uast.Node) *uast.Position {
+	last := n.EndPosition
+	if last != nil && last.Line == 0 {
+		last = nil
+	}
+	for _, child := range n.Children {
+		pos := endPosition(child)
+		if pos != nil && (last == nil || pos.Line > last.Line || pos.Line == last.Line && pos.Col > last.Col) {
+			last = pos
+		}
+	}
+	return last
+}
@@ -0,0 +1,877 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var ErrInvalidQuery = errors.NewKind("invalid query %q: %s")
+
+// Query is a compiled query over a UAST, written in a subset of XPath 1.0
+// where nodes are the UAST nodes, named by their internal types:
+//
+//   //IfStatement[@role='If' and @role='Statement']
+//   //*[@role='Function' and @role='Declaration'][count(.//*[@role='If']) > 2]
+//   /CompilationUnit/*[1]/@token
+//   count(//*[@role='Call'])
+//
+// Location paths may use the child (the default), descendant,
+// descendant-or-self (//), self (.), parent (..), ancestor,
+// ancestor-or-self, following-sibling and preceding-sibling axes, with
+// name, * or node() tests, and may end in an attribute. Predicates may
+// compare, with = != < <= > and >=, strings, numbers, paths and these
+// attributes:
+// * @role: every role of the node, such as 'Identifier'
+// * @type: the internal type
+// * @token: the token, or the token property set by some drivers instead
+// * @line, @col, @offset, @end-line, @end-col and @end-offset: the
+//   positions
+// * any other name: the property of the node with that name
+//
+// Comparisons with paths or attributes are true if any of their values
+// satisfies them. Expressions can be combined with and, or and not(), and
+// the count(), position(), last(), contains() and starts-with() functions
+// are supported. A number as predicate selects the node at that position,
+// counted backwards from n on the ancestor and preceding-sibling axes of n.
+// Selected nodes are returned in document order.
+type Query struct {
+	Text string
+	expr queryExpr
+}
+
+// Querier prints the nodes of the analyzed files selected by a Query, or
+// its value if it's not a set of nodes.
+type Querier struct {
+	Query *Query
+}
+
+// QuerierOptions are the options of Querier.
+type QuerierOptions struct {
+	Query string `long:"query" description:"XPath-like query, e.g. //*[@role='If']" required:"true"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "query",
+		Description: "Run XPath-like query over the UAST",
+		Options:     func() interface{} { return &QuerierOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			query, err := ParseQuery(env.Options.(*QuerierOptions).Query)
+			if err != nil {
+				return nil, err
+			}
+			return Querier{Query: query}, nil
+		},
+	})
+}
+
+func (q Querier) Exec(n *uast.Node) error {
+	return q.ExecFiles([]*File{{UAST: n}})
+}
+
+func (q Querier) ExecFiles(files []*File) error {
+	for _, file := range files {
+		switch result := q.Query.Eval(file.UAST).(type) {
+		case []*uast.Node:
+			for _, n := range result {
+				var line, col uint32
+				if pos := startPosition(n); pos != nil {
+					line, col = pos.Line, pos.Col
+				}
+				fmt.Printf("File:%s, Line:%d, Col:%d, Type:%s, Roles:%s, Token:%s\n",
+					file.Path, line, col, n.InternalType, strings.Join(roleNames(n.Roles), "|"), n.Token)
+			}
+		case []string:
+			for _, s := range result {
+				fmt.Printf("File:%s, Value:%s\n", file.Path, s)
+			}
+		default:
+			fmt.Printf("File:%s, Value:%v\n", file.Path, result)
+		}
+	}
+	return nil
+}
+
+// ParseQuery compiles a query.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, ErrInvalidQuery.New(text, err.Error())
+	}
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err == nil && p.peek().kind != queryEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, ErrInvalidQuery.New(text, err.Error())
+	}
+	return &Query{Text: text, expr: expr}, nil
+}
+
+// QueryNodes returns the nodes contained in n selected by a query.
+func QueryNodes(n *uast.Node, query string) ([]*uast.Node, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(n), nil
+}
+
+// Eval evaluates the query with n as root, returning a []*uast.Node, a
+// []string of attribute values, a float64 or a bool.
+func (q *Query) Eval(n *uast.Node) interface{} {
+	doc := &uast.Node{Children: []*uast.Node{n}}
+	engine := &queryEngine{doc: doc, parents: make(map[*uast.Node]*uast.Node), order: map[*uast.Node]int{doc: 0}}
+	engine.index(doc)
+
+	v := q.expr.eval(&queryContext{engine: engine, node: n, position: 1, size: 1})
+	switch v.kind {
+	case queryNodes:
+		nodes := make([]*uast.Node, 0, len(v.nodes))
+		for _, node := range v.nodes {
+			if node == doc {
+				node = n
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	case queryStrings:
+		return v.strs
+	case queryNumber:
+		return v.num
+	default:
+		return v.b
+	}
+}
+
+// Select returns the nodes selected by the query with n as root, nil if it
+// doesn't evaluate to nodes.
+func (q *Query) Select(n *uast.Node) []*uast.Node {
+	nodes, _ := q.Eval(n).([]*uast.Node)
+	return nodes
+}
+
+// Matches returns whether the query selects any node, or evaluates to true
+// or a non-zero number, with n as root.
+func (q *Query) Matches(n *uast.Node) bool {
+	switch v := q.Eval(n).(type) {
+	case []*uast.Node:
+		return len(v) > 0
+	case []string:
+		return len(v) > 0
+	case float64:
+		return v != 0
+	default:
+		return v.(bool)
+	}
+}
+
+const (
+	queryNodes = iota
+	queryStrings
+	queryNumber
+	queryBool
+)
+
+type queryValue struct {
+	kind  int
+	nodes []*uast.Node
+	strs  []string
+	num   float64
+	b     bool
+}
+
+func (v queryValue) truth() bool {
+	switch v.kind {
+	case queryNodes:
+		return len(v.nodes) > 0
+	case queryStrings:
+		return len(v.strs) > 0
+	case queryNumber:
+		return v.num != 0
+	default:
+		return v.b
+	}
+}
+
+func (v queryValue) strings() []string {
+	switch v.kind {
+	case queryNodes:
+		strs := make([]string, len(v.nodes))
+		for i, n := range v.nodes {
+			strs[i] = n.Token
+		}
+		return strs
+	case queryStrings:
+		return v.strs
+	case queryNumber:
+		return []string{strconv.FormatFloat(v.num, 'f', -1, 64)}
+	default:
+		return []string{strconv.FormatBool(v.b)}
+	}
+}
+
+func (v queryValue) numbers() []float64 {
+	if v.kind == queryNumber {
+		return []float64{v.num}
+	}
+	var nums []float64
+	for _, s := range v.strings() {
+		if num, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+			nums = append(nums, num)
+		}
+	}
+	return nums
+}
+
+type queryEngine struct {
+	doc     *uast.Node
+	parents map[*uast.Node]*uast.Node
+	// order is the position of every node in document order.
+	order map[*uast.Node]int
+}
+
+func (e *queryEngine) index(n *uast.Node) {
+	for _, child := range n.Children {
+		e.parents[child] = n
+		e.order[child] = len(e.order)
+		e.index(child)
+	}
+}
+
+type queryContext struct {
+	engine   *queryEngine
+	node     *uast.Node
+	position int
+	size     int
+}
+
+type queryExpr interface {
+	eval(ctx *queryContext) queryValue
+}
+
+type queryLiteral struct{ value queryValue }
+
+func (l *queryLiteral) eval(*queryContext) queryValue { return l.value }
+
+type queryAttr struct{ name string }
+
+func (a *queryAttr) eval(ctx *queryContext) queryValue {
+	return queryValue{kind: queryStrings, strs: nodeAttribute(ctx.node, a.name)}
+}
+
+func nodeAttribute(n *uast.Node, name string) []string {
+	position := func(pos *uast.Position, field func(*uast.Position) uint32) []string {
+		if pos == nil || pos.Line == 0 {
+			return nil
+		}
+		return []string{strconv.Itoa(int(field(pos)))}
+	}
+	line := func(p *uast.Position) uint32 { return p.Line }
+	col := func(p *uast.Position) uint32 { return p.Col }
+	offset := func(p *uast.Position) uint32 { return p.Offset }
+
+	switch name {
+	case "role":
+		return roleNames(n.Roles)
+	case "type":
+		return []string{n.InternalType}
+	case "token":
+		return []string{nodeToken(n)}
+	case "line":
+		return position(n.StartPosition, line)
+	case "col":
+		return position(n.StartPosition, col)
+	case "offset":
+		return position(n.StartPosition, offset)
+	case "end-line":
+		return position(n.EndPosition, line)
+	case "end-col":
+		return position(n.EndPosition, col)
+	case "end-offset":
+		return position(n.EndPosition, offset)
+	}
+	if value, ok := n.Properties[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+type queryLogic struct {
+	and         bool
+	left, right queryExpr
+}
+
+func (l *queryLogic) eval(ctx *queryContext) queryValue {
+	left := l.left.eval(ctx).truth()
+	if l.and && !left || !l.and && left {
+		return queryValue{kind: queryBool, b: left}
+	}
+	return queryValue{kind: queryBool, b: l.right.eval(ctx).truth()}
+}
+
+type queryComparison struct {
+	op          string
+	left, right queryExpr
+}
+
+func (c *queryComparison) eval(ctx *queryContext) queryValue {
+	left, right := c.left.eval(ctx), c.right.eval(ctx)
+	result := false
+	switch {
+	case left.kind == queryBool || right.kind == queryBool:
+		result = compareQueryValues(c.op, boolNumber(left.truth()), boolNumber(right.truth()))
+	case left.kind == queryNumber || right.kind == queryNumber || (c.op != "=" && c.op != "!="):
+		for _, a := range left.numbers() {
+			for _, b := range right.numbers() {
+				result = result || compareQueryValues(c.op, a, b)
+			}
+		}
+	default:
+		for _, a := range left.strings() {
+			for _, b := range right.strings() {
+				result = result || (a == b) == (c.op == "=")
+			}
+		}
+	}
+	return queryValue{kind: queryBool, b: result}
+}
+
+func boolNumber(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareQueryValues(op string, a, b float64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+type queryFunction struct {
+	name string
+	args []queryExpr
+}
+
+// queryFunctions are the supported functions and their number of
+// arguments.
+var queryFunctions = map[string]int{
+	"count":       1,
+	"position":    0,
+	"last":        0,
+	"not":         1,
+	"contains":    2,
+	"starts-with": 2,
+}
+
+func (f *queryFunction) eval(ctx *queryContext) queryValue {
+	firstString := func(e queryExpr) string {
+		if strs := e.eval(ctx).strings(); len(strs) > 0 {
+			return strs[0]
+		}
+		return ""
+	}
+
+	switch f.name {
+	case "count":
+		v := f.args[0].eval(ctx)
+		count := len(v.strings())
+		if v.kind != queryNodes && v.kind != queryStrings {
+			count = 1
+		}
+		return queryValue{kind: queryNumber, num: float64(count)}
+	case "position":
+		return queryValue{kind: queryNumber, num: float64(ctx.position)}
+	case "last":
+		return queryValue{kind: queryNumber, num: float64(ctx.size)}
+	case "not":
+		return queryValue{kind: queryBool, b: !f.args[0].eval(ctx).truth()}
+	case "contains":
+		return queryValue{kind: queryBool, b: strings.Contains(firstString(f.args[0]), firstString(f.args[1]))}
+	default:
+		return queryValue{kind: queryBool, b: strings.HasPrefix(firstString(f.args[0]), firstString(f.args[1]))}
+	}
+}
+
+type queryPath struct {
+	absolute bool
+	steps    []*queryStep
+	// attr is the attribute selected at the end of the path, if any.
+	attr string
+}
+
+type queryStep struct {
+	axis string
+	// test is a name, * or node().
+	test       string
+	predicates []queryExpr
+}
+
+func (p *queryPath) eval(ctx *queryContext) queryValue {
+	nodes := []*uast.Node{ctx.node}
+	if p.absolute {
+		nodes = []*uast.Node{ctx.engine.doc}
+	}
+
+	for _, step := range p.steps {
+		var next []*uast.Node
+		seen := make(map[*uast.Node]bool)
+		for _, n := range nodes {
+			for _, candidate := range step.eval(ctx.engine, n) {
+				if !seen[candidate] {
+					seen[candidate] = true
+					next = append(next, candidate)
+				}
+			}
+		}
+		// node sets are in document order, even if the predicates of the
+		// step saw the nodes of reverse axes in reverse order
+		sort.Slice(next, func(i, j int) bool {
+			return ctx.engine.order[next[i]] < ctx.engine.order[next[j]]
+		})
+		nodes = next
+	}
+
+	if p.attr == "" {
+		return queryValue{kind: queryNodes, nodes: nodes}
+	}
+	var strs []string
+	for _, n := range nodes {
+		strs = append(strs, nodeAttribute(n, p.attr)...)
+	}
+	return queryValue{kind: queryStrings, strs: strs}
+}
+
+func (s *queryStep) eval(engine *queryEngine, n *uast.Node) []*uast.Node {
+	var candidates []*uast.Node
+	for _, candidate := range engine.axis(s.axis, n) {
+		if s.test == "node()" || s.test == "*" && candidate != engine.doc || candidate.InternalType == s.test {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	for _, predicate := range s.predicates {
+		var kept []*uast.Node
+		for i, candidate := range candidates {
+			ctx := &queryContext{engine: engine, node: candidate, position: i + 1, size: len(candidates)}
+			v := predicate.eval(ctx)
+			if v.kind == queryNumber && v.num == float64(i+1) || v.kind != queryNumber && v.truth() {
+				kept = append(kept, candidate)
+			}
+		}
+		candidates = kept
+	}
+	return candidates
+}
+
+var queryAxes = map[string]bool{
+	"child": true, "descendant": true, "descendant-or-self": true, "self": true,
+	"parent": true, "ancestor": true, "ancestor-or-self": true,
+	"following-sibling": true, "preceding-sibling": true,
+}
+
+// axis returns the nodes of an axis of n, in document order but for the
+// ancestor axes, which are in reverse order.
+func (e *queryEngine) axis(axis string, n *uast.Node) []*uast.Node {
+	switch axis {
+	case "child":
+		return n.Children
+	case "self":
+		return []*uast.Node{n}
+	case "parent":
+		if parent, ok := e.parents[n]; ok {
+			return []*uast.Node{parent}
+		}
+		return nil
+	case "ancestor", "ancestor-or-self":
+		var nodes []*uast.Node
+		if axis == "ancestor-or-self" {
+			nodes = append(nodes, n)
+		}
+		for parent, ok := e.parents[n]; ok; parent, ok = e.parents[parent] {
+			nodes = append(nodes, parent)
+		}
+		return nodes
+	case "following-sibling", "preceding-sibling":
+		parent, ok := e.parents[n]
+		if !ok {
+			return nil
+		}
+		for i, sibling := range parent.Children {
+			if sibling != n {
+				continue
+			}
+			if axis == "following-sibling" {
+				return parent.Children[i+1:]
+			}
+			nodes := make([]*uast.Node, 0, i)
+			for j := i - 1; j >= 0; j-- {
+				nodes = append(nodes, parent.Children[j])
+			}
+			return nodes
+		}
+		return nil
+	default:
+		var nodes []*uast.Node
+		if axis == "descendant-or-self" {
+			nodes = append(nodes, n)
+		}
+		var visit func(*uast.Node)
+		visit = func(n *uast.Node) {
+			for _, child := range n.Children {
+				nodes = append(nodes, child)
+				visit(child)
+			}
+		}
+		visit(n)
+		return nodes
+	}
+}
+
+const (
+	queryEOF = iota
+	queryName
+	queryString
+	queryNumberToken
+	querySymbol
+)
+
+type queryToken struct {
+	kind int
+	text string
+}
+
+// querySymbols are the symbols of the language, longest first.
+var querySymbols = []string{"//", "::", "..", "!=", "<=", ">=", "/", "[", "]", "(", ")", "@", ",", "=", "<", ">", "*", "."}
+
+func lexQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(text)
+	isNameStart := func(r rune) bool { return unicode.IsLetter(r) || r == '_' }
+	isName := func(r rune) bool {
+		return isNameStart(r) || unicode.IsDigit(r) || r == '-' || r == '.'
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, queryToken{queryString, string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, queryToken{queryNumberToken, string(runes[i:end])})
+			i = end
+		case isNameStart(r):
+			end := i
+			for end < len(runes) && isName(runes[end]) {
+				end++
+			}
+			// a name can't end with a dot, as in `Name/.`
+			for runes[end-1] == '.' {
+				end--
+			}
+			tokens = append(tokens, queryToken{queryName, string(runes[i:end])})
+			i = end
+		default:
+			found := false
+			for _, symbol := range querySymbols {
+				if strings.HasPrefix(string(runes[i:]), symbol) {
+					tokens = append(tokens, queryToken{querySymbol, symbol})
+					i += len([]rune(symbol))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+			}
+		}
+	}
+	return append(tokens, queryToken{kind: queryEOF}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) peekAt(offset int) queryToken {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return queryToken{kind: queryEOF}
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != queryEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) isSymbol(symbols ...string) bool {
+	t := p.peek()
+	if t.kind != querySymbol {
+		return false
+	}
+	for _, s := range symbols {
+		if t.text == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) expect(symbol string) error {
+	if !p.isSymbol(symbol) {
+		return fmt.Errorf("expected %q, found %q", symbol, p.peek().text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *queryParser) parseExpr() (queryExpr, error) {
+	return p.parseLogic(false)
+}
+
+// parseLogic parses an or expression, or an and expression if and is set.
+func (p *queryParser) parseLogic(and bool) (queryExpr, error) {
+	operand := func() (queryExpr, error) {
+		if and {
+			return p.parseComparison()
+		}
+		return p.parseLogic(true)
+	}
+	keyword := "or"
+	if and {
+		keyword = "and"
+	}
+
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == queryName && p.peek().text == keyword {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryLogic{and: and, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isSymbol("=", "!=", "<", "<=", ">", ">=") {
+		op := p.next().text
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryComparison{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == querySymbol && t.text == "(":
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case t.kind == queryString:
+		p.next()
+		return &queryLiteral{queryValue{kind: queryStrings, strs: []string{t.text}}}, nil
+	case t.kind == queryNumberToken:
+		p.next()
+		num, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return &queryLiteral{queryValue{kind: queryNumber, num: num}}, nil
+	case t.kind == querySymbol && t.text == "@":
+		p.next()
+		name := p.next()
+		if name.kind != queryName {
+			return nil, fmt.Errorf("expected attribute name, found %q", name.text)
+		}
+		return &queryAttr{name: name.text}, nil
+	case t.kind == queryName && p.peekAt(1).kind == querySymbol && p.peekAt(1).text == "(" && t.text != "node":
+		return p.parseFunction()
+	default:
+		return p.parsePath()
+	}
+}
+
+func (p *queryParser) parseFunction() (queryExpr, error) {
+	name := p.next().text
+	arity, ok := queryFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.next()
+
+	f := &queryFunction{name: name}
+	for !p.isSymbol(")") {
+		if len(f.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, arg)
+	}
+	p.next()
+
+	if len(f.args) != arity {
+		return nil, fmt.Errorf("%s expects %d arguments, found %d", name, arity, len(f.args))
+	}
+	return f, nil
+}
+
+func (p *queryParser) startsStep() bool {
+	return p.peek().kind == queryName || p.isSymbol("*", ".", "..", "@")
+}
+
+func (p *queryParser) parsePath() (queryExpr, error) {
+	path := &queryPath{}
+	switch {
+	case p.isSymbol("/"):
+		p.next()
+		path.absolute = true
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.isSymbol("//"):
+		p.next()
+		path.absolute = true
+		path.steps = append(path.steps, &queryStep{axis: "descendant-or-self", test: "node()"})
+	case !p.startsStep():
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+
+	for {
+		if p.isSymbol("@") {
+			p.next()
+			name := p.next()
+			if name.kind != queryName {
+				return nil, fmt.Errorf("expected attribute name, found %q", name.text)
+			}
+			path.attr = name.text
+			return path, nil
+		}
+
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+
+		switch {
+		case p.isSymbol("/"):
+			p.next()
+		case p.isSymbol("//"):
+			p.next()
+			path.steps = append(path.steps, &queryStep{axis: "descendant-or-self", test: "node()"})
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *queryParser) parseStep() (*queryStep, error) {
+	step := &queryStep{axis: "child"}
+	switch {
+	case p.isSymbol("."):
+		p.next()
+		return &queryStep{axis: "self", test: "node()"}, nil
+	case p.isSymbol(".."):
+		p.next()
+		return &queryStep{axis: "parent", test: "node()"}, nil
+	case p.peek().kind == queryName && p.peekAt(1).kind == querySymbol && p.peekAt(1).text == "::":
+		axis := p.next().text
+		if !queryAxes[axis] {
+			return nil, fmt.Errorf("unknown axis %s", axis)
+		}
+		p.next()
+		step.axis = axis
+	}
+
+	switch t := p.next(); {
+	case t.kind == querySymbol && t.text == "*":
+		step.test = "*"
+	case t.kind == queryName && t.text == "node" && p.isSymbol("("):
+		p.next()
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		step.test = "node()"
+	case t.kind == queryName:
+		step.test = t.text
+	default:
+		return nil, fmt.Errorf("expected node test, found %q", t.text)
+	}
+
+	for p.isSymbol("[") {
+		p.next()
+		predicate, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		step.predicates = append(step.predicates, predicate)
+	}
+	return step, nil
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestQuery(t *testing.T) {
+	require := require.New(t)
+
+	n := readFixture(t, "fixtures/npath/ifelse.java.json")
+	cases := []struct {
+		query    string
+		expected interface{}
+	}{
+		{"count(//MethodInvocation)", 2.0},
+		{"//*[@role='If' and @role='Statement']/@type", []string{"IfStatement", "Block", "Block"}},
+		{"//IfStatement[@role='If'][@role='Statement']/@type", []string{"IfStatement"}},
+		{"//SimpleName/@token", []string{"Code", "code", "System", "out", "println", "System", "out", "println"}},
+		{"//MethodDeclaration[count(.//MethodInvocation) > 1]/SimpleName/@token", []string{"code"}},
+		{"//SimpleName[@token='out']/../@type", []string{"QualifiedName", "QualifiedName"}},
+		{"//BooleanLiteral/ancestor::IfStatement/@type", []string{"IfStatement"}},
+		{"//SimpleName[@token='out']/ancestor::*/@type", []string{
+			"CompilationUnit", "TypeDeclaration", "MethodDeclaration", "Block", "IfStatement", "Block",
+			"ExpressionStatement", "MethodInvocation", "QualifiedName",
+			"Block", "ExpressionStatement", "MethodInvocation", "QualifiedName",
+		}},
+		{"//SimpleName[@token='out']/ancestor::*[3]/@type", []string{"ExpressionStatement", "ExpressionStatement"}},
+		{"//BooleanLiteral[@line=6]/@booleanValue", []string{"false"}},
+		{"/CompilationUnit/*[1]/@type", []string{"TypeDeclaration"}},
+		{"//QualifiedName/*[last()]/@token", []string{"out", "out"}},
+		{"//QualifiedName/*[position() = 1]/following-sibling::*/@token", []string{"out", "out"}},
+		{"//SimpleName[starts-with(@token, 'print') or contains(@token, 'yst')]/@token", []string{"System", "println", "System", "println"}},
+		{"count(//*[not(@role)]) = 0", true},
+		{"//Nothing", []*uast.Node{}},
+	}
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		require.NoError(err, c.query)
+		result := q.Eval(n)
+		if nodes, ok := result.([]*uast.Node); ok && len(nodes) == 0 {
+			result = []*uast.Node{}
+		}
+		require.Equal(c.expected, result, c.query)
+	}
+
+	nodes, err := QueryNodes(n, "//IfStatement")
+	require.NoError(err)
+	require.Len(nodes, 1)
+	require.Equal("IfStatement", nodes[0].InternalType)
+
+	nodes, err = QueryNodes(n, "/")
+	require.NoError(err)
+	require.Equal([]*uast.Node{n}, nodes)
+
+	q, err := ParseQuery("//BooleanLiteral")
+	require.NoError(err)
+	require.True(q.Matches(n))
+	require.Nil((&Query{expr: &queryLiteral{queryValue{kind: queryNumber, num: 1}}}).Select(n))
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	require := require.New(t)
+
+	for _, query := range []string{
+		"//",
+		"//If[",
+		"//If[@role='If'",
+		"//*[@role=\"If]",
+		"//If[unknown(1)]",
+		"//If[count()]",
+		"sideways::If",
+		"//If]",
+		"#",
+	} {
+		_, err := ParseQuery(query)
+		require.True(ErrInvalidQuery.Is(err), query)
+	}
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// ToolInfo describes a tool registered to be run by name, e.g. as a command
+// of the CLI.
+type ToolInfo struct {
+	// Name is the name of the tool, such as npath.
+	Name string
+	// Description is a one line description of the tool.
+	Description string
+	// Options, if not nil, returns a new pointer to the struct of the
+	// options of the tool. Its fields are tagged as in go-flags, with long,
+	// description and default tags, and are set from the command line.
+	Options func() interface{}
+	// New returns the tool configured with the options, validating and
+	// converting them, e.g. loading the files they name.
+	New func(env *ToolEnv) (Tooler, error)
+}
+
+// ToolEnv is the environment a registered tool is created in.
+type ToolEnv struct {
+	// Options are the options returned by ToolInfo.Options, nil if there
+	// are none.
+	Options interface{}
+	// Paths are the paths of the files the tool is run over.
+	Paths []string
+	// UAST tells if Paths are parse responses saved in JSON, with the source
+	// code at the same path without the .json extension.
+	UAST bool
+	// Language is the language of the files, empty when it is left to the
+	// server to detect it.
+	Language string
+	// Parse parses source code, of the language given by the extension of
+	// filename if Language is empty, e.g. to parse a code pattern.
+	Parse func(filename, content string) (*uast.Node, error)
+}
+
+// SourcePaths returns the paths of the source code of the files, without
+// the .json extension of the saved parse responses.
+func (env *ToolEnv) SourcePaths() []string {
+	if !env.UAST {
+		return env.Paths
+	}
+	paths := make([]string, len(env.Paths))
+	for i, p := range env.Paths {
+		paths[i] = strings.TrimSuffix(p, ".json")
+	}
+	return paths
+}
+
+var registry = struct {
+	sync.Mutex
+	tools map[string]*ToolInfo
+}{tools: make(map[string]*ToolInfo)}
+
+// Register registers a tool. It panics if the tool has no name or
+// constructor, or if there is already a tool with its name.
+func Register(info *ToolInfo) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if info.Name == "" || info.New == nil {
+		panic("tools: registered tool without name or constructor")
+	}
+	if _, ok := registry.tools[info.Name]; ok {
+		panic(fmt.Sprintf("tools: tool %s registered twice", info.Name))
+	}
+	registry.tools[info.Name] = info
+}
+
+// LookupTool returns the registered tool with a name, nil if there is none.
+func LookupTool(name string) *ToolInfo {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.tools[name]
+}
+
+// Tools returns the registered tools, sorted by name.
+func Tools() []*ToolInfo {
+	registry.Lock()
+	defer registry.Unlock()
+
+	tools := make([]*ToolInfo, 0, len(registry.tools))
+	for _, info := range registry.tools {
+		tools = append(tools, info)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
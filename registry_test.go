@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestRegistry(t *testing.T) {
+	require := require.New(t)
+
+	var names []string
+	for _, info := range Tools() {
+		names = append(names, info.Name)
+	}
+	require.Subset(names, []string{"dummy", "cyclomatic", "npath", "abc", "ck", "stats"})
+	require.True(sort.StringsAreSorted(names))
+
+	info := LookupTool("stats")
+	require.NotNil(info)
+	options := info.Options()
+	options.(*StatsOptions).Top = 5
+	tool, err := info.New(&ToolEnv{Options: options})
+	require.NoError(err)
+	require.Equal(Stats{Top: 5}, tool)
+
+	tool, err = LookupTool("npath").New(&ToolEnv{})
+	require.NoError(err)
+	require.Equal(NPath{}, tool)
+
+	// the options are validated and converted
+	info = LookupTool("show")
+	options = info.Options()
+	options.(*ShowOptions).Roles = []string{"If"}
+	tool, err = info.New(&ToolEnv{Options: options})
+	require.NoError(err)
+	require.Equal(Show{Filter: TokenFilter{Roles: []uast.Role{uast.If}}}, tool)
+	options.(*ShowOptions).Roles = []string{"Nope"}
+	_, err = info.New(&ToolEnv{Options: options})
+	require.Error(err)
+
+	info = LookupTool("lint")
+	_, err = info.New(&ToolEnv{Options: &LintOptions{Rules: "fixtures/missing.yml"}})
+	require.Error(err)
+
+	// search parses its pattern in the language of the files
+	info = LookupTool("search")
+	var parsed string
+	_, err = info.New(&ToolEnv{
+		Options: &SearchOptions{Pattern: "$X + 1"},
+		Paths:   []string{"a.py"},
+		Parse: func(filename, content string) (*uast.Node, error) {
+			parsed = filename + ":" + content
+			return &uast.Node{InternalType: "Module"}, nil
+		},
+	})
+	require.NoError(err)
+	require.Regexp(`^pattern\.py:\S+ \+ 1$`, parsed)
+
+	// even if the files are saved parse responses
+	_, err = info.New(&ToolEnv{
+		Options: &SearchOptions{Pattern: "$X + 1"},
+		Paths:   []string{"a.py.json"},
+		UAST:    true,
+		Parse: func(filename, content string) (*uast.Node, error) {
+			parsed = filename
+			return &uast.Node{InternalType: "Module"}, nil
+		},
+	})
+	require.NoError(err)
+	require.Equal("pattern.py", parsed)
+
+	require.Nil(LookupTool("nope"))
+	require.Panics(func() { Register(&ToolInfo{Name: "npath", New: info.New}) })
+	require.Panics(func() { Register(&ToolInfo{Name: "nope"}) })
+}
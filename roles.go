@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrUnknownRole is returned when parsing a name that is not a UAST role.
+var ErrUnknownRole = errors.NewKind("unknown UAST role: %s")
+
+var rolesByName = func() map[string]uast.Role {
+	roles := make(map[string]uast.Role)
+	for value := range uast.Role_name {
+		if r := uast.Role(value); r != uast.Invalid {
+			roles[strings.ToLower(r.String())] = r
+		}
+	}
+	return roles
+}()
+
+// ParseRole returns the role with the given name, as returned by
+// uast.Role.String. The name is case insensitive and underscores are
+// ignored, so both `LessThan` and `LESS_THAN` are accepted.
+func ParseRole(name string) (uast.Role, error) {
+	role, ok := rolesByName[strings.ToLower(strings.Replace(name, "_", "", -1))]
+	if !ok {
+		return uast.Invalid, ErrUnknownRole.New(name)
+	}
+	return role, nil
+}
+
+// ParseRoles parses a list of role names with ParseRole.
+func ParseRoles(names []string) ([]uast.Role, error) {
+	var roles []uast.Role
+	for _, name := range names {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// roleNames returns the names of the roles.
+func roleNames(roles []uast.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.String())
+	}
+	return names
+}
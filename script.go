@@ -0,0 +1,559 @@
+package tools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var ErrScript = errors.NewKind("script %s failed on %s: %s")
+
+// dialect guards the resolve flags of the Starlark dialect while a script
+// is compiled.
+var dialect sync.Mutex
+
+// withDialect calls fn with the resolve flags of the dialect of the scripts
+// set, and restores them after but AllowRecursion. The other flags are only
+// read while compiling, but AllowRecursion is read by Starlark on every
+// call, so once a script is compiled recursive functions are allowed to the
+// other users of Starlark in the same program too.
+func withDialect(fn func()) {
+	dialect.Lock()
+	defer dialect.Unlock()
+
+	flags := []*bool{
+		&resolve.AllowRecursion, &resolve.AllowNestedDef, &resolve.AllowLambda,
+		&resolve.AllowFloat, &resolve.AllowSet, &resolve.AllowGlobalReassign,
+	}
+	saved := make([]bool, len(flags))
+	for i, flag := range flags {
+		// scripts walk trees, so they need recursion and the usual Python
+		// features
+		saved[i], *flag = *flag, true
+	}
+	defer func() {
+		for i, flag := range flags {
+			if flag != &resolve.AllowRecursion {
+				*flag = saved[i]
+			}
+		}
+	}()
+
+	fn()
+}
+
+// Script runs a Starlark script (https://github.com/bazelbuild/starlark)
+// over the UAST of every analyzed file, to compute custom metrics and
+// findings without writing a tool in Go. The script is run once per file
+// with these globals:
+// * root: the root node of the file
+// * file and language: the path and language of the file
+// * walk(node): the node and all its descendants, in preorder
+// * children_of_roles(node, roles, not_roles=[]) and
+//   deep_children_of_roles(node, roles, not_roles=[]): the children or
+//   descendants with all the roles and none of the not_roles, as role names
+// * functions(node) and function_name(node): the function declarations
+//   under the node, and the name of one
+// * query(node, query): the result of a Query with the node as root, a list
+//   of nodes or strings, a number or a bool
+// * metric(name, value, node=None): emits a metric, at the position of the
+//   node if given
+// * finding(node, message, rule="script", severity="warning"): emits a
+//   finding at the position of the node, or of the whole file if None
+//
+// Nodes are read-only and have the internal_type, token, roles (a tuple of
+// role names), properties (a dict), children (a tuple of nodes), start_line,
+// start_col, start_offset, end_line, end_col and end_offset (None if
+// unknown) and line (the first line of the node or its descendants, None if
+// unknown) attributes, and a has_roles(*roles) method.
+//
+// For example, to count the parameters of every function:
+//
+//   for f in functions(root):
+//       args = children_of_roles(f, ["Function", "Argument"])
+//       metric("params:" + function_name(f), len(args), f)
+//       if len(args) > 5:
+//           finding(f, "too many parameters", rule="params")
+//
+// Scripts can loop and recurse, so they fail if they run out of time.
+type Script struct {
+	// Program is the compiled script.
+	Program *ScriptProgram
+	// Format is the output format: text or json.
+	Format string
+	// Timeout is the time the script can run per file, none if zero.
+	Timeout time.Duration
+}
+
+// ScriptOptions are the options of Script.
+type ScriptOptions struct {
+	Script  string        `long:"script" description:"Starlark script run over every file" required:"true"`
+	Format  string        `long:"format" description:"output format: text or json" default:"text"`
+	Timeout time.Duration `long:"timeout" description:"time the script can run per file, none if zero" default:"1m"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "script",
+		Description: "Run Starlark script computing custom metrics and findings",
+		Options:     func() interface{} { return &ScriptOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*ScriptOptions)
+			program, err := LoadScript(o.Script)
+			if err != nil {
+				return nil, err
+			}
+			return Script{Program: program, Format: o.Format, Timeout: o.Timeout}, nil
+		},
+	})
+}
+
+// ScriptProgram is a compiled Starlark script.
+type ScriptProgram struct {
+	Name    string
+	program *starlark.Program
+}
+
+// scriptGlobals are the names of the globals of the scripts.
+var scriptGlobals = []string{
+	"root", "file", "language", "walk", "children_of_roles", "deep_children_of_roles",
+	"functions", "function_name", "query", "metric", "finding",
+}
+
+// LoadScript reads and compiles a script.
+func LoadScript(path string) (*ScriptProgram, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScript(path, string(content))
+}
+
+// ParseScript compiles a script, name is used in the errors.
+func ParseScript(name, source string) (*ScriptProgram, error) {
+	isGlobal := func(name string) bool {
+		for _, g := range scriptGlobals {
+			if g == name {
+				return true
+			}
+		}
+		return false
+	}
+	var program *starlark.Program
+	var err error
+	withDialect(func() {
+		_, program, err = starlark.SourceProgram(name, source, isGlobal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptProgram{Name: name, program: program}, nil
+}
+
+func (s Script) Exec(n *uast.Node) error {
+	return s.ExecFiles([]*File{{UAST: n}})
+}
+
+func (s Script) ExecFiles(files []*File) error {
+	results := &Results{}
+	for _, file := range files {
+		if err := s.Program.Run(file, results, s.Timeout); err != nil {
+			return err
+		}
+	}
+	return results.Write(os.Stdout, s.Format)
+}
+
+// Run runs the script over the file, adding the metrics and findings it
+// emits to results, and fails if it runs for longer than timeout, if not
+// zero. The output of print goes to the standard error.
+//
+// Starlark can't interrupt a script, so on timeout it is left running in
+// the background, failing as soon as it calls any of the globals.
+func (sp *ScriptProgram) Run(file *File, results *Results, timeout time.Duration) error {
+	run := &scriptRun{file: file, results: &Results{}, nodes: make(map[*uast.Node]*scriptNode)}
+	thread := &starlark.Thread{Name: file.Path, Print: func(_ *starlark.Thread, msg string) {
+		fmt.Fprintln(os.Stderr, msg)
+	}}
+
+	done := make(chan error, 1)
+	globals := run.globals()
+	go func() {
+		_, err := sp.program.Init(thread, globals)
+		done <- err
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case err = <-done:
+	case <-expired:
+		atomic.StoreInt32(&run.canceled, 1)
+		return ErrScript.New(sp.Name, file.Path, fmt.Sprintf("timed out after %s", timeout))
+	}
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return ErrScript.New(sp.Name, file.Path, evalErr.Backtrace())
+		}
+		return ErrScript.New(sp.Name, file.Path, err)
+	}
+
+	results.Metrics = append(results.Metrics, run.results.Metrics...)
+	results.Findings = append(results.Findings, run.results.Findings...)
+	return nil
+}
+
+// scriptRun is the run of a script over a file.
+type scriptRun struct {
+	file    *File
+	results *Results
+	// nodes are the values of the nodes seen by the script, so the same
+	// node is always the same value.
+	nodes map[*uast.Node]*scriptNode
+	// canceled is set, atomically, when the run times out.
+	canceled int32
+}
+
+func (r *scriptRun) node(n *uast.Node) *scriptNode {
+	if sn, ok := r.nodes[n]; ok {
+		return sn
+	}
+	sn := &scriptNode{node: n, run: r, id: uint32(len(r.nodes))}
+	r.nodes[n] = sn
+	return sn
+}
+
+func (r *scriptRun) nodeList(nodes []*uast.Node) *starlark.List {
+	values := make([]starlark.Value, len(nodes))
+	for i, n := range nodes {
+		values[i] = r.node(n)
+	}
+	return starlark.NewList(values)
+}
+
+func (r *scriptRun) globals() starlark.StringDict {
+	return starlark.StringDict{
+		"root":                   r.node(r.file.UAST),
+		"file":                   starlark.String(r.file.Path),
+		"language":               starlark.String(fileLanguage(r.file)),
+		"walk":                   r.builtin("walk", r.walk),
+		"children_of_roles":      r.builtin("children_of_roles", r.childrenOfRoles(childrenOfRoles)),
+		"deep_children_of_roles": r.builtin("deep_children_of_roles", r.childrenOfRoles(deepChildrenOfRoles)),
+		"functions":              r.builtin("functions", r.functions),
+		"function_name":          r.builtin("function_name", r.functionName),
+		"query":                  r.builtin("query", r.query),
+		"metric":                 r.builtin("metric", r.metric),
+		"finding":                r.builtin("finding", r.finding),
+	}
+}
+
+type scriptBuiltin = func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error)
+
+// builtin returns a global function failing once the run is canceled.
+func (r *scriptRun) builtin(name string, fn scriptBuiltin) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if atomic.LoadInt32(&r.canceled) != 0 {
+			return nil, fmt.Errorf("%s: script canceled", b.Name())
+		}
+		return fn(thread, b, args, kwargs)
+	})
+}
+
+func (r *scriptRun) walk(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var sn *scriptNode
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &sn); err != nil {
+		return nil, err
+	}
+	var nodes []*uast.Node
+	var visit func(n *uast.Node)
+	visit = func(n *uast.Node) {
+		nodes = append(nodes, n)
+		for _, child := range n.Children {
+			visit(child)
+		}
+	}
+	visit(sn.node)
+	return r.nodeList(nodes), nil
+}
+
+func (r *scriptRun) childrenOfRoles(find func(*uast.Node, []uast.Role, []uast.Role) []*uast.Node) scriptBuiltin {
+	return func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var sn *scriptNode
+		var roles, notRoles *starlark.List
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "node", &sn, "roles", &roles, "not_roles?", &notRoles); err != nil {
+			return nil, err
+		}
+		and, err := scriptRoles(roles)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fn.Name(), err)
+		}
+		not, err := scriptRoles(notRoles)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fn.Name(), err)
+		}
+		return r.nodeList(find(sn.node, and, not)), nil
+	}
+}
+
+// scriptRoles parses a list of role names.
+func scriptRoles(list *starlark.List) ([]uast.Role, error) {
+	if list == nil {
+		return nil, nil
+	}
+	var names []string
+	for i := 0; i < list.Len(); i++ {
+		name, ok := starlark.AsString(list.Index(i))
+		if !ok {
+			return nil, fmt.Errorf("role names must be strings, got %s", list.Index(i).Type())
+		}
+		names = append(names, name)
+	}
+	return ParseRoles(names)
+}
+
+func (r *scriptRun) functions(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var sn *scriptNode
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &sn); err != nil {
+		return nil, err
+	}
+	var nodes []*uast.Node
+	for _, f := range Functions(sn.node) {
+		nodes = append(nodes, f.Node)
+	}
+	return r.nodeList(nodes), nil
+}
+
+func (r *scriptRun) functionName(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var sn *scriptNode
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &sn); err != nil {
+		return nil, err
+	}
+	return starlark.String(functionName(sn.node)), nil
+}
+
+func (r *scriptRun) query(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var sn *scriptNode
+	var text string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &sn, &text); err != nil {
+		return nil, err
+	}
+	q, err := ParseQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	switch result := q.Eval(sn.node).(type) {
+	case []*uast.Node:
+		return r.nodeList(result), nil
+	case []string:
+		values := make([]starlark.Value, len(result))
+		for i, s := range result {
+			values[i] = starlark.String(s)
+		}
+		return starlark.NewList(values), nil
+	case float64:
+		return starlark.Float(result), nil
+	default:
+		return starlark.Bool(result.(bool)), nil
+	}
+}
+
+func (r *scriptRun) metric(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	var node starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "value", &value, "node?", &node); err != nil {
+		return nil, err
+	}
+	sn, err := optionalNode(fn, node)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := starlark.AsFloat(value)
+	if !ok {
+		if b, isBool := value.(starlark.Bool); isBool {
+			f, ok = boolNumber(bool(b)), true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: value must be a number, got %s", fn.Name(), value.Type())
+	}
+
+	m := &Metric{File: r.file.Path, Name: name, Value: f}
+	if sn != nil {
+		if pos := startPosition(sn.node); pos != nil {
+			m.Line = int(pos.Line)
+		}
+	}
+	r.results.Metrics = append(r.results.Metrics, m)
+	return starlark.None, nil
+}
+
+func (r *scriptRun) finding(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var node starlark.Value
+	var message string
+	rule, severity := "script", SeverityWarning
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs,
+		"node", &node, "message", &message, "rule?", &rule, "severity?", &severity); err != nil {
+		return nil, err
+	}
+	sn, err := optionalNode(fn, node)
+	if err != nil {
+		return nil, err
+	}
+	switch severity {
+	case SeverityError, SeverityWarning, SeverityInfo:
+	default:
+		return nil, fmt.Errorf("%s: unknown severity %s", fn.Name(), severity)
+	}
+
+	f := &Finding{File: r.file.Path, Rule: rule, Message: message, Severity: severity}
+	if sn != nil {
+		if pos := startPosition(sn.node); pos != nil {
+			f.Line, f.Col = int(pos.Line), int(pos.Col)
+		}
+	}
+	r.results.Findings = append(r.results.Findings, f)
+	return starlark.None, nil
+}
+
+// optionalNode returns the node of a node argument, nil if it is None.
+func optionalNode(fn *starlark.Builtin, v starlark.Value) (*scriptNode, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *scriptNode:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%s: for parameter node: got %s, want Node or None", fn.Name(), v.Type())
+	}
+}
+
+// scriptNode is the read-only value of a node in scripts.
+type scriptNode struct {
+	node *uast.Node
+	run  *scriptRun
+	id   uint32
+}
+
+var scriptNodeAttrs = []string{
+	"children", "end_col", "end_line", "end_offset", "has_roles", "internal_type", "line",
+	"properties", "roles", "start_col", "start_line", "start_offset", "token",
+}
+
+func (sn *scriptNode) String() string {
+	return fmt.Sprintf("Node(%s)", describeNode(sn.node))
+}
+
+func (sn *scriptNode) Type() string          { return "Node" }
+func (sn *scriptNode) Freeze()               {}
+func (sn *scriptNode) Truth() starlark.Bool  { return starlark.True }
+func (sn *scriptNode) Hash() (uint32, error) { return sn.id, nil }
+func (sn *scriptNode) AttrNames() []string   { return scriptNodeAttrs }
+
+func (sn *scriptNode) Attr(name string) (starlark.Value, error) {
+	n := sn.node
+	position := func(pos *uast.Position, field uint32) starlark.Value {
+		if pos == nil || pos.Line == 0 {
+			return starlark.None
+		}
+		return starlark.MakeUint(uint(field))
+	}
+
+	switch name {
+	case "internal_type":
+		return starlark.String(n.InternalType), nil
+	case "token":
+		return starlark.String(nodeToken(n)), nil
+	case "roles":
+		var roles starlark.Tuple
+		for _, name := range roleNames(n.Roles) {
+			roles = append(roles, starlark.String(name))
+		}
+		return roles, nil
+	case "properties":
+		keys := make([]string, 0, len(n.Properties))
+		for k := range n.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		props := starlark.NewDict(len(keys))
+		for _, k := range keys {
+			if err := props.SetKey(starlark.String(k), starlark.String(n.Properties[k])); err != nil {
+				return nil, err
+			}
+		}
+		props.Freeze()
+		return props, nil
+	case "children":
+		children := make(starlark.Tuple, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = sn.run.node(child)
+		}
+		return children, nil
+	case "line":
+		if pos := startPosition(n); pos != nil {
+			return starlark.MakeUint(uint(pos.Line)), nil
+		}
+		return starlark.None, nil
+	case "has_roles":
+		return starlark.NewBuiltin("has_roles", sn.hasRoles), nil
+	}
+
+	start, end := n.StartPosition, n.EndPosition
+	var zero uast.Position
+	if start == nil {
+		start = &zero
+	}
+	if end == nil {
+		end = &zero
+	}
+	switch name {
+	case "start_line":
+		return position(start, start.Line), nil
+	case "start_col":
+		return position(start, start.Col), nil
+	case "start_offset":
+		return position(start, start.Offset), nil
+	case "end_line":
+		return position(end, end.Line), nil
+	case "end_col":
+		return position(end, end.Col), nil
+	case "end_offset":
+		return position(end, end.Offset), nil
+	}
+	return nil, nil
+}
+
+func (sn *scriptNode) hasRoles(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 {
+		return nil, fmt.Errorf("%s: unexpected keyword arguments", fn.Name())
+	}
+	var names []string
+	for _, arg := range args {
+		name, ok := starlark.AsString(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s: role names must be strings, got %s", fn.Name(), arg.Type())
+		}
+		names = append(names, name)
+	}
+	roles, err := ParseRoles(names)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fn.Name(), err)
+	}
+	return starlark.Bool(containsRoles(sn.node, roles, nil)), nil
+}
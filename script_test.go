@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/resolve"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestScript(t *testing.T) {
+	require := require.New(t)
+
+	program, err := ParseScript("test.star", `
+def depth(n):
+    if not n.children:
+        return 1
+    return 1 + max([depth(c) for c in n.children])
+
+for f in functions(root):
+    ifs = deep_children_of_roles(f, ["Statement", "If"], ["Then", "Else"])
+    metric("ifs:" + function_name(f), len(ifs), f)
+    metric("depth:" + function_name(f), depth(f), f)
+    for i in ifs:
+        cond = children_of_roles(i, ["If", "Condition"])
+        if cond and cond[0].has_roles("Literal", "Boolean"):
+            finding(cond[0], "constant condition " + cond[0].properties["booleanValue"], rule="constant-if", severity="info")
+
+metric("calls", query(root, "count(//*[@type='MethodInvocation'])"))
+names = [n for n in walk(root) if n.internal_type == "SimpleName"]
+metric("names", len(names))
+metric("same", len({n: True for n in names + walk(root)}) == len(walk(root)))
+`)
+	require.NoError(err)
+
+	file := &File{Path: "ifelse.java", Language: "java", UAST: readFixture(t, "fixtures/npath/ifelse.java.json")}
+	result := &Results{}
+	require.NoError(program.Run(file, result, 0))
+	require.Equal([]*Metric{
+		{File: "ifelse.java", Line: 2, Name: "ifs:code", Value: 1},
+		{File: "ifelse.java", Line: 2, Name: "depth:code", Value: 8},
+		{File: "ifelse.java", Name: "calls", Value: 2},
+		{File: "ifelse.java", Name: "names", Value: 8},
+		{File: "ifelse.java", Name: "same", Value: 1},
+	}, result.Metrics)
+	require.Equal([]*Finding{{
+		File:     "ifelse.java",
+		Line:     3,
+		Col:      10,
+		Rule:     "constant-if",
+		Message:  "constant condition true",
+		Severity: SeverityInfo,
+	}}, result.Findings)
+}
+
+func TestScriptReadOnly(t *testing.T) {
+	require := require.New(t)
+
+	root := &uast.Node{InternalType: "File", Properties: map[string]string{"a": "b"}}
+	for _, source := range []string{
+		`root.properties["a"] = "c"`,
+		`root.token = "x"`,
+		`root.children.append(root)`,
+		`finding(root, "x", severity="fatal")`,
+		`children_of_roles(root, ["Nope"])`,
+		`metric("x", "y")`,
+	} {
+		program, err := ParseScript("test.star", source)
+		require.NoError(err, source)
+		err = program.Run(&File{Path: "a", UAST: root}, &Results{}, 0)
+		require.True(ErrScript.Is(err), source)
+	}
+
+	_, err := ParseScript("test.star", "undefined_global(root)")
+	require.Error(err)
+}
+
+func TestScriptNoneNode(t *testing.T) {
+	require := require.New(t)
+
+	program, err := ParseScript("test.star", `
+metric("x", 1, node=None)
+finding(None, "whole file", rule="file")
+`)
+	require.NoError(err)
+
+	results := &Results{}
+	require.NoError(program.Run(&File{Path: "a", UAST: &uast.Node{}}, results, 0))
+	require.Equal([]*Metric{{File: "a", Name: "x", Value: 1}}, results.Metrics)
+	require.Equal([]*Finding{{File: "a", Rule: "file", Message: "whole file", Severity: SeverityWarning}}, results.Findings)
+
+	program, err = ParseScript("test.star", `metric("x", 1, node=1)`)
+	require.NoError(err)
+	require.True(ErrScript.Is(program.Run(&File{Path: "a", UAST: &uast.Node{}}, &Results{}, 0)))
+}
+
+func TestScriptDialect(t *testing.T) {
+	require := require.New(t)
+
+	program, err := ParseScript("test.star", "def f(n):\n    return f(n - 1) if n else 0\nmetric(\"f\", f(3))\n")
+	require.NoError(err)
+	require.False(resolve.AllowFloat)
+	require.False(resolve.AllowGlobalReassign)
+	// read on every call, so it stays set
+	require.True(resolve.AllowRecursion)
+	require.NoError(program.Run(&File{Path: "a", UAST: &uast.Node{}}, &Results{}, 0))
+}
+
+func TestScriptTimeout(t *testing.T) {
+	require := require.New(t)
+
+	program, err := ParseScript("test.star", "while True:\n    walk(root)\n")
+	require.NoError(err)
+
+	start := time.Now()
+	err = program.Run(&File{Path: "a", UAST: &uast.Node{}}, &Results{}, 100*time.Millisecond)
+	require.True(ErrScript.Is(err))
+	require.Contains(err.Error(), "timed out after 100ms")
+	require.True(time.Since(start) < 5*time.Second, "took %s", time.Since(start))
+}
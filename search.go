@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Search prints the nodes of the analyzed files matching a Pattern, along
+// with the subtrees bound to its metavariables.
+type Search struct {
+	Pattern *Pattern
+}
+
+// SearchOptions are the options of Search.
+type SearchOptions struct {
+	Pattern string `long:"pattern" description:"code to look for, with metavariables such as $X matching any subtree" required:"true"`
+	Wrap    string `long:"wrap" description:"code making the pattern valid on its own, with %s in place of the pattern"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "search",
+		Description: "Run structural search of a code pattern",
+		Options:     func() interface{} { return &SearchOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*SearchOptions)
+			source, start, end, err := PatternSource(o.Pattern, o.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			// the pattern is parsed in the language of the files, guessed
+			// from the first one if not given
+			var ext string
+			if paths := env.SourcePaths(); len(paths) > 0 {
+				ext = filepath.Ext(paths[0])
+			}
+			root, err := env.Parse("pattern"+ext, source)
+			if err != nil {
+				return nil, err
+			}
+			pattern, err := NewPattern(o.Pattern, root, start, end)
+			if err != nil {
+				return nil, err
+			}
+			return Search{Pattern: pattern}, nil
+		},
+	})
+}
+
+func (s Search) Exec(n *uast.Node) error {
+	return s.ExecFiles([]*File{{UAST: n}})
+}
+
+func (s Search) ExecFiles(files []*File) error {
+	for _, finding := range s.Find(files) {
+		fmt.Print(finding)
+	}
+	return nil
+}
+
+// Find returns a finding for every match of the pattern in the files.
+func (s Search) Find(files []*File) []*Finding {
+	var findings []*Finding
+	for _, file := range files {
+		for _, match := range s.Pattern.Find(file.UAST) {
+			finding := &Finding{File: file.Path, Rule: "search", Message: "matches " + s.Pattern.Text}
+			if bindings := match.String(); bindings != "" {
+				finding.Message += " with " + bindings
+			}
+			if pos := startPosition(match.Node); pos != nil {
+				finding.Line, finding.Col = int(pos.Line), int(pos.Col)
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,369 @@
+package tools
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Show renders the UAST of the analyzed files in a readable way: an
+// indented text tree, a Graphviz DOT graph with the nodes colored by role,
+// or a self-contained HTML page with collapsible nodes which highlight the
+// source code they span on hover.
+//
+// Every node is shown with its internal type, roles, token and positions.
+// Nodes not selected by Filter are left out, their children taking their
+// place, so filtering by roles shows the skeleton of the tree made of the
+// nodes with them.
+type Show struct {
+	// Format is the output format: text, dot or html.
+	Format string
+	// MaxDepth, if not zero, is the depth of the deepest nodes shown, the
+	// root being at depth one.
+	MaxDepth int
+	// Filter selects the nodes shown, the root is always shown.
+	Filter TokenFilter
+}
+
+// ShowOptions are the options of Show.
+type ShowOptions struct {
+	Format       string   `long:"format" description:"output format: text, dot or html" default:"text"`
+	MaxDepth     int      `long:"max-depth" description:"depth of the deepest nodes shown, 0 for no limit"`
+	Roles        []string `long:"role" description:"only show the nodes with this role, can be repeated"`
+	ExcludeRoles []string `long:"exclude-role" description:"don't show the nodes with this role, can be repeated"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "show",
+		Description: "Run UAST visualizer",
+		Options:     func() interface{} { return &ShowOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*ShowOptions)
+			filter, err := (&TokenOptions{Roles: o.Roles, ExcludeRoles: o.ExcludeRoles}).Filter()
+			if err != nil {
+				return nil, err
+			}
+			return Show{Format: o.Format, MaxDepth: o.MaxDepth, Filter: filter}, nil
+		},
+	})
+}
+
+// ShowNode is a node of the tree shown.
+type ShowNode struct {
+	Node     *uast.Node
+	Children []*ShowNode
+	// Hidden is the number of nodes under this one left out by the depth
+	// limit.
+	Hidden int
+}
+
+func (s Show) Exec(n *uast.Node) error {
+	return s.ExecFiles([]*File{{UAST: n}})
+}
+
+func (s Show) ExecFiles(files []*File) error {
+	switch s.Format {
+	case "", "text":
+		return s.WriteText(os.Stdout, files)
+	case "dot":
+		return s.WriteDOT(os.Stdout, files)
+	case "html":
+		return s.WriteHTML(os.Stdout, files)
+	default:
+		return ErrUnknownFormat.New(s.Format)
+	}
+}
+
+// Tree returns the tree shown for the node.
+func (s Show) Tree(n *uast.Node) *ShowNode {
+	root := &ShowNode{Node: n}
+	root.Children, root.Hidden = s.children(n, 2)
+	return root
+}
+
+// children returns the nodes shown under n at the given depth and the
+// number of the ones hidden by the depth limit.
+func (s Show) children(n *uast.Node, depth int) ([]*ShowNode, int) {
+	var shown []*ShowNode
+	hidden := 0
+	for _, child := range n.Children {
+		if !s.Filter.Match(child) {
+			grandchildren, h := s.children(child, depth)
+			shown = append(shown, grandchildren...)
+			hidden += h
+			continue
+		}
+		if s.MaxDepth > 0 && depth > s.MaxDepth {
+			hidden += s.count(child)
+			continue
+		}
+		sn := &ShowNode{Node: child}
+		sn.Children, sn.Hidden = s.children(child, depth+1)
+		shown = append(shown, sn)
+	}
+	return shown, hidden
+}
+
+// count returns the number of nodes in n, including itself, selected by the
+// filter.
+func (s Show) count(n *uast.Node) int {
+	c := 0
+	if s.Filter.Match(n) {
+		c++
+	}
+	for _, child := range n.Children {
+		c += s.count(child)
+	}
+	return c
+}
+
+// Label returns the internal type, roles, token and positions of the node
+// in a line, e.g. `SimpleName [Expression, Identifier] "x" 3:5-3:6`.
+func (sn *ShowNode) Label() string {
+	n := sn.Node
+	label := fmt.Sprintf("%s [%s]", n.InternalType, strings.Join(roleNames(n.Roles), ", "))
+	if n.Token != "" {
+		label += fmt.Sprintf(" %q", n.Token)
+	}
+	if pos := n.StartPosition; pos != nil && pos.Line != 0 {
+		label += fmt.Sprintf(" %d:%d", pos.Line, pos.Col)
+		if end := n.EndPosition; end != nil && end.Line != 0 {
+			label += fmt.Sprintf("-%d:%d", end.Line, end.Col)
+		}
+	}
+	return label
+}
+
+// WriteText writes the trees as indented text, with a line per node.
+func (s Show) WriteText(w io.Writer, files []*File) error {
+	for _, file := range files {
+		if file.Path != "" {
+			if _, err := fmt.Fprintf(w, "File:%s\n", file.Path); err != nil {
+				return err
+			}
+		}
+		if err := writeShowText(w, s.Tree(file.UAST), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeShowText(w io.Writer, sn *ShowNode, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	if _, err := fmt.Fprintf(w, "%s%s\n", indent, sn.Label()); err != nil {
+		return err
+	}
+	for _, child := range sn.Children {
+		if err := writeShowText(w, child, depth+1); err != nil {
+			return err
+		}
+	}
+	if sn.Hidden > 0 {
+		if _, err := fmt.Fprintf(w, "%s  ... %d more nodes\n", indent, sn.Hidden); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roleColors are the colors of the nodes in DOT graphs by role, the first
+// role of the node in the list winning.
+var roleColors = []struct {
+	role  uast.Role
+	color string
+}{
+	{uast.Comment, "gray85"},
+	{uast.Function, "lightskyblue"},
+	{uast.Type, "plum"},
+	{uast.Import, "wheat"},
+	{uast.Call, "palegreen"},
+	{uast.Literal, "khaki"},
+	{uast.Identifier, "lightpink"},
+	{uast.Operator, "orange"},
+	{uast.Statement, "lightsteelblue"},
+	{uast.Expression, "honeydew"},
+}
+
+func roleColor(n *uast.Node) string {
+	for _, rc := range roleColors {
+		if containsRoles(n, []uast.Role{rc.role}, nil) {
+			return rc.color
+		}
+	}
+	return "white"
+}
+
+// WriteDOT writes the trees in Graphviz DOT format, a cluster per file, with
+// the nodes colored by role.
+func (s Show) WriteDOT(w io.Writer, files []*File) error {
+	if _, err := fmt.Fprintln(w, "digraph uast {\n\tnode [shape=box, style=filled];"); err != nil {
+		return err
+	}
+	id := 0
+	var write func(sn *ShowNode, indent string) (int, error)
+	write = func(sn *ShowNode, indent string) (int, error) {
+		self := id
+		id++
+		if _, err := fmt.Fprintf(w, "%sn%d [label=%s, fillcolor=%s];\n", indent, self, dotQuote(sn.Label()), roleColor(sn.Node)); err != nil {
+			return 0, err
+		}
+		for _, child := range sn.Children {
+			c, err := write(child, indent)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := fmt.Fprintf(w, "%sn%d -> n%d;\n", indent, self, c); err != nil {
+				return 0, err
+			}
+		}
+		if sn.Hidden > 0 {
+			if _, err := fmt.Fprintf(w, "%sn%d [label=\"... %d more nodes\", style=dashed];\n%sn%d -> n%d [style=dashed];\n",
+				indent, id, sn.Hidden, indent, self, id); err != nil {
+				return 0, err
+			}
+			id++
+		}
+		return self, nil
+	}
+
+	for i, file := range files {
+		if _, err := fmt.Fprintf(w, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, dotQuote(file.Path)); err != nil {
+			return err
+		}
+		if _, err := write(s.Tree(file.UAST), "\t\t"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, "\t}"); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// showHTMLNode is a node of the HTML page, with the span of source code it
+// highlights as offsets in the UTF-16 code units of JavaScript strings, or
+// -1 if unknown.
+type showHTMLNode struct {
+	Label      string
+	Start, End int
+	Children   []*showHTMLNode
+	Hidden     int
+}
+
+type showHTMLFile struct {
+	Path   string
+	Source string
+	Root   *showHTMLNode
+}
+
+// WriteHTML writes the trees as a self-contained HTML page. Nodes can be
+// collapsed, and hovering them highlights the source code spanned by their
+// positions, if the source of the file is known.
+func (s Show) WriteHTML(w io.Writer, files []*File) error {
+	var data []*showHTMLFile
+	for _, file := range files {
+		offsets := utf16Offsets(file.Content)
+		var convert func(sn *ShowNode) *showHTMLNode
+		convert = func(sn *ShowNode) *showHTMLNode {
+			hn := &showHTMLNode{Label: sn.Label(), Start: -1, End: -1, Hidden: sn.Hidden}
+			start, end := startPosition(sn.Node), endPosition(sn.Node)
+			if start != nil && end != nil && int(start.Offset) <= int(end.Offset) && int(end.Offset) < len(offsets) {
+				hn.Start, hn.End = offsets[start.Offset], offsets[end.Offset]
+			}
+			for _, child := range sn.Children {
+				hn.Children = append(hn.Children, convert(child))
+			}
+			return hn
+		}
+		data = append(data, &showHTMLFile{Path: file.Path, Source: file.Content, Root: convert(s.Tree(file.UAST))})
+	}
+	return showHTMLTemplate.Execute(w, data)
+}
+
+// utf16Offsets maps the byte offsets of s to offsets in UTF-16 code units.
+func utf16Offsets(s string) []int {
+	offsets := make([]int, len(s)+1)
+	units := 0
+	for i, r := range s {
+		size := utf8.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+		for j := 0; j < size && i+j < len(s); j++ {
+			offsets[i+j] = units
+		}
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	offsets[len(s)] = units
+	return offsets
+}
+
+var showHTMLTemplate = template.Must(template.New("show").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>UAST</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+.file { display: flex; align-items: flex-start; gap: 2em; margin-bottom: 2em; }
+.tree, .tree ul { list-style: none; padding-left: 1.2em; margin: 0; font-family: monospace; }
+.tree summary, .tree .leaf { cursor: default; white-space: pre; }
+.tree summary:hover, .tree .leaf:hover { background: #ffe08a; }
+.hidden { color: #888; }
+pre.source { position: sticky; top: 0; background: #f6f8fa; padding: 1em; margin: 0; }
+pre.source mark { background: #ffe08a; }
+</style>
+</head>
+<body>
+{{define "node"}}<li>{{if or .Children .Hidden}}<details open><summary data-start="{{.Start}}" data-end="{{.End}}">{{.Label}}</summary><ul>{{range .Children}}{{template "node" .}}{{end}}{{if .Hidden}}<li class="hidden">... {{.Hidden}} more nodes</li>{{end}}</ul></details>{{else}}<span class="leaf" data-start="{{.Start}}" data-end="{{.End}}">{{.Label}}</span>{{end}}</li>
+{{end}}{{range .}}<h2>{{.Path}}</h2>
+<div class="file">
+<ul class="tree">{{template "node" .Root}}</ul>
+{{if .Source}}<pre class="source">{{.Source}}</pre>{{end}}
+</div>
+{{end}}<script>
+document.querySelectorAll(".file").forEach(function(file) {
+	var source = file.querySelector("pre.source");
+	if (!source) {
+		return;
+	}
+	var text = source.textContent;
+	function show(start, end) {
+		source.textContent = "";
+		if (start < 0) {
+			source.appendChild(document.createTextNode(text));
+			return;
+		}
+		var mark = document.createElement("mark");
+		mark.textContent = text.slice(start, end);
+		source.appendChild(document.createTextNode(text.slice(0, start)));
+		source.appendChild(mark);
+		source.appendChild(document.createTextNode(text.slice(end)));
+		mark.scrollIntoView({block: "nearest"});
+	}
+	file.querySelectorAll("[data-start]").forEach(function(node) {
+		node.addEventListener("mouseover", function(event) {
+			event.stopPropagation();
+			show(+node.dataset.start, +node.dataset.end);
+		});
+		node.addEventListener("mouseout", function() {
+			show(-1, -1);
+		});
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func showFixture() *File {
+	// x = "é" + f()
+	return &File{Path: "a.x", Content: `x = "é" + f()`, UAST: &uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "Assign", Roles: []uast.Role{uast.Assignment, uast.Statement}, Children: []*uast.Node{
+			{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: "x",
+				StartPosition: &uast.Position{Offset: 0, Line: 1, Col: 1}, EndPosition: &uast.Position{Offset: 1, Line: 1, Col: 2}},
+			{InternalType: "Plus", Roles: []uast.Role{uast.Operator, uast.Add}, Children: []*uast.Node{
+				{InternalType: "String", Roles: []uast.Role{uast.Literal}, Token: "é",
+					StartPosition: &uast.Position{Offset: 4, Line: 1, Col: 5}, EndPosition: &uast.Position{Offset: 8, Line: 1, Col: 8}},
+				{InternalType: "Call", Roles: []uast.Role{uast.Call}, Children: []*uast.Node{
+					{InternalType: "Name", Roles: []uast.Role{uast.Identifier, uast.Call, uast.Callee}, Token: "f",
+						StartPosition: &uast.Position{Offset: 11, Line: 1, Col: 11}, EndPosition: &uast.Position{Offset: 14, Line: 1, Col: 14}},
+				}},
+			}},
+		}},
+	}}}
+}
+
+func TestShowText(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{showFixture()}
+	var buf bytes.Buffer
+	require.NoError(Show{}.WriteText(&buf, files))
+	require.Equal(`File:a.x
+File []
+  Assign [Assignment, Statement]
+    Name [Identifier] "x" 1:1-1:2
+    Plus [Operator, Add]
+      String [Literal] "é" 1:5-1:8
+      Call [Call]
+        Name [Identifier, Call, Callee] "f" 1:11-1:14
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(Show{MaxDepth: 3}.WriteText(&buf, files))
+	require.Equal(`File:a.x
+File []
+  Assign [Assignment, Statement]
+    Name [Identifier] "x" 1:1-1:2
+    Plus [Operator, Add]
+      ... 3 more nodes
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(Show{Filter: TokenFilter{Roles: []uast.Role{uast.Identifier, uast.Operator}}}.WriteText(&buf, files))
+	require.Equal(`File:a.x
+File []
+  Name [Identifier] "x" 1:1-1:2
+  Plus [Operator, Add]
+    Name [Identifier, Call, Callee] "f" 1:11-1:14
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(Show{MaxDepth: 2, Filter: TokenFilter{ExcludeRoles: []uast.Role{uast.Statement}}}.WriteText(&buf, files))
+	require.Equal(`File:a.x
+File []
+  Name [Identifier] "x" 1:1-1:2
+  Plus [Operator, Add]
+    ... 3 more nodes
+`, buf.String())
+}
+
+func TestShowDOT(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	require.NoError(Show{MaxDepth: 3}.WriteDOT(&buf, []*File{showFixture()}))
+	require.Equal(`digraph uast {
+	node [shape=box, style=filled];
+	subgraph cluster_0 {
+		label="a.x";
+		n0 [label="File []", fillcolor=white];
+		n1 [label="Assign [Assignment, Statement]", fillcolor=lightsteelblue];
+		n2 [label="Name [Identifier] \"x\" 1:1-1:2", fillcolor=lightpink];
+		n1 -> n2;
+		n3 [label="Plus [Operator, Add]", fillcolor=orange];
+		n4 [label="... 3 more nodes", style=dashed];
+		n3 -> n4 [style=dashed];
+		n1 -> n3;
+		n0 -> n1;
+	}
+}
+`, buf.String())
+}
+
+func TestShowHTML(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	require.NoError(Show{}.WriteHTML(&buf, []*File{showFixture()}))
+	html := buf.String()
+	// offsets are in UTF-16 code units, é takes two bytes but one unit
+	require.Contains(html, `<summary data-start="0" data-end="13">Assign [Assignment, Statement]</summary>`)
+	require.Contains(html, `<span class="leaf" data-start="4" data-end="7">String [Literal] &#34;é&#34; 1:5-1:8</span>`)
+	require.Contains(html, `<summary data-start="0" data-end="13">File []</summary>`)
+	require.Contains(html, `<pre class="source">x = &#34;é&#34; &#43; f()</pre>`)
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Stats reports, per language, how the drivers annotate the UAST of the
+// analyzed files: histograms of roles, role combinations and internal types,
+// the fraction of nodes without roles or positions, and the internal types
+// whose nodes never get a semantic role, that is, a role other than the
+// generic Expression, Statement, Incomplete and Unannotated. The metric
+// tools don't see the constructs behind those, so they point at the gaps
+// making them undercount.
+//
+// The language of a file is the one it was parsed as or, if it was left to
+// the server to detect it, the one of its extension.
+type Stats struct {
+	// Format is the output format: text or json.
+	Format string
+	// Top, if not zero, is the number of entries of each histogram shown in
+	// text format.
+	Top int
+}
+
+// StatsOptions are the options of Stats.
+type StatsOptions struct {
+	Format string `long:"format" description:"output format: text or json" default:"text"`
+	Top    int    `long:"top" description:"number of entries of each histogram shown in text format, 0 for all" default:"20"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "stats",
+		Description: "Run UAST role and internal type statistics",
+		Options:     func() interface{} { return &StatsOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*StatsOptions)
+			return Stats{Format: o.Format, Top: o.Top}, nil
+		},
+	})
+}
+
+type StatsData struct {
+	Languages []*LanguageStats `json:"languages"`
+}
+
+type LanguageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Nodes    int    `json:"nodes"`
+	// NoRoles is the fraction of nodes without roles.
+	NoRoles float64 `json:"no_roles"`
+	// NoPositions is the fraction of nodes without start position.
+	NoPositions float64       `json:"no_positions"`
+	Roles       []*StatsCount `json:"roles"`
+	// RoleSets are the combinations of roles of the nodes, with the role
+	// names sorted and joined by `+`.
+	RoleSets      []*StatsCount `json:"role_sets"`
+	InternalTypes []*StatsCount `json:"internal_types"`
+	// NoSemanticRole are the internal types whose nodes never have a
+	// semantic role, with their number of nodes.
+	NoSemanticRole []*StatsCount `json:"no_semantic_role"`
+}
+
+// StatsCount is an entry of a histogram.
+type StatsCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// genericRoles are the roles which say nothing about the construct of a
+// node.
+var genericRoles = map[uast.Role]bool{
+	uast.Expression:  true,
+	uast.Statement:   true,
+	uast.Incomplete:  true,
+	uast.Unannotated: true,
+}
+
+func (s Stats) Exec(n *uast.Node) error {
+	return s.ExecFiles([]*File{{UAST: n}})
+}
+
+func (s Stats) ExecFiles(files []*File) error {
+	data := StatsOf(files)
+	switch s.Format {
+	case "", "text":
+		return data.WriteText(os.Stdout, s.Top)
+	case "json":
+		return data.WriteJSON(os.Stdout)
+	default:
+		return ErrUnknownFormat.New(s.Format)
+	}
+}
+
+// StatsOf computes the statistics of the files, by language name.
+func StatsOf(files []*File) *StatsData {
+	type counts struct {
+		stats         *LanguageStats
+		noRoles       int
+		noPositions   int
+		roles         map[string]int
+		roleSets      map[string]int
+		internalTypes map[string]int
+		semantic      map[string]bool
+	}
+	byLanguage := make(map[string]*counts)
+	data := &StatsData{}
+	for _, file := range files {
+		lang := fileLanguage(file)
+		c, ok := byLanguage[lang]
+		if !ok {
+			c = &counts{
+				stats:         &LanguageStats{Language: lang},
+				roles:         make(map[string]int),
+				roleSets:      make(map[string]int),
+				internalTypes: make(map[string]int),
+				semantic:      make(map[string]bool),
+			}
+			byLanguage[lang] = c
+			data.Languages = append(data.Languages, c.stats)
+		}
+		c.stats.Files++
+
+		var visit func(n *uast.Node)
+		visit = func(n *uast.Node) {
+			c.stats.Nodes++
+			c.internalTypes[n.InternalType]++
+			if len(n.Roles) == 0 {
+				c.noRoles++
+			}
+			if n.StartPosition == nil || n.StartPosition.Line == 0 {
+				c.noPositions++
+			}
+			names := roleNames(n.Roles)
+			for _, name := range names {
+				c.roles[name]++
+			}
+			sort.Strings(names)
+			c.roleSets[strings.Join(names, "+")]++
+			for _, r := range n.Roles {
+				if !genericRoles[r] {
+					c.semantic[n.InternalType] = true
+				}
+			}
+			for _, child := range n.Children {
+				visit(child)
+			}
+		}
+		visit(file.UAST)
+	}
+
+	for _, c := range byLanguage {
+		ls := c.stats
+		if ls.Nodes > 0 {
+			ls.NoRoles = float64(c.noRoles) / float64(ls.Nodes)
+			ls.NoPositions = float64(c.noPositions) / float64(ls.Nodes)
+		}
+		ls.Roles = histogram(c.roles)
+		ls.RoleSets = histogram(c.roleSets)
+		ls.InternalTypes = histogram(c.internalTypes)
+		noSemantic := make(map[string]int)
+		for t, count := range c.internalTypes {
+			if !c.semantic[t] {
+				noSemantic[t] = count
+			}
+		}
+		ls.NoSemanticRole = histogram(noSemantic)
+	}
+	sort.Slice(data.Languages, func(i, j int) bool {
+		return data.Languages[i].Language < data.Languages[j].Language
+	})
+	return data
+}
+
+// histogram returns the counts sorted from the highest, then by name.
+func histogram(counts map[string]int) []*StatsCount {
+	h := make([]*StatsCount, 0, len(counts))
+	for name, count := range counts {
+		h = append(h, &StatsCount{Name: name, Count: count})
+	}
+	sort.Slice(h, func(i, j int) bool {
+		if h[i].Count != h[j].Count {
+			return h[i].Count > h[j].Count
+		}
+		return h[i].Name < h[j].Name
+	})
+	return h
+}
+
+func (ls *LanguageStats) String() string {
+	return fmt.Sprintf("Language:%s, Files:%d, Nodes:%d, NoRoles:%.2f%%, NoPositions:%.2f%%\n",
+		ls.Language, ls.Files, ls.Nodes, 100*ls.NoRoles, 100*ls.NoPositions)
+}
+
+// WriteText writes the statistics of every language followed by its
+// histograms, of at most top entries if not zero.
+func (sd *StatsData) WriteText(w io.Writer, top int) error {
+	for _, ls := range sd.Languages {
+		if _, err := fmt.Fprint(w, ls); err != nil {
+			return err
+		}
+		for _, h := range []struct {
+			key    string
+			counts []*StatsCount
+		}{
+			{"Role", ls.Roles},
+			{"RoleSet", ls.RoleSets},
+			{"InternalType", ls.InternalTypes},
+			{"NoSemanticRole", ls.NoSemanticRole},
+		} {
+			for i, c := range h.counts {
+				if top > 0 && i == top {
+					break
+				}
+				if _, err := fmt.Fprintf(w, "%s:%s, Count:%d\n", h.key, c.Name, c.Count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the statistics as a JSON document.
+func (sd *StatsData) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sd)
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func TestStatsOf(t *testing.T) {
+	require := require.New(t)
+
+	pos := &uast.Position{Offset: 0, Line: 1, Col: 1}
+	files := []*File{
+		{Path: "a.py", UAST: &uast.Node{InternalType: "Module", Roles: []uast.Role{uast.File}, Children: []*uast.Node{
+			{InternalType: "If", Roles: []uast.Role{uast.Statement, uast.If}, StartPosition: pos},
+			{InternalType: "Expr", Roles: []uast.Role{uast.Expression}, StartPosition: pos},
+			{InternalType: "Pass"},
+		}}},
+		{Path: "b.py", UAST: &uast.Node{InternalType: "Module", Roles: []uast.Role{uast.File}}},
+		{Path: "A.java", Language: "java", UAST: &uast.Node{InternalType: "CompilationUnit", Roles: []uast.Role{uast.File}}},
+	}
+
+	data := StatsOf(files)
+	require.Len(data.Languages, 2)
+	require.Equal("java", data.Languages[0].Language)
+
+	py := data.Languages[1]
+	require.Equal("python", py.Language)
+	require.Equal(2, py.Files)
+	require.Equal(5, py.Nodes)
+	require.Equal(0.2, py.NoRoles)
+	require.Equal(0.6, py.NoPositions)
+	require.Equal([]*StatsCount{{"File", 2}, {"Expression", 1}, {"If", 1}, {"Statement", 1}}, py.Roles)
+	require.Equal([]*StatsCount{{"File", 2}, {"", 1}, {"Expression", 1}, {"If+Statement", 1}}, py.RoleSets)
+	require.Equal([]*StatsCount{{"Module", 2}, {"Expr", 1}, {"If", 1}, {"Pass", 1}}, py.InternalTypes)
+	require.Equal([]*StatsCount{{"Expr", 1}, {"Pass", 1}}, py.NoSemanticRole)
+	require.Equal("Language:python, Files:2, Nodes:5, NoRoles:20.00%, NoPositions:60.00%\n", py.String())
+
+	var buf bytes.Buffer
+	require.NoError(data.WriteJSON(&buf))
+	var out struct {
+		Languages []map[string]interface{} `json:"languages"`
+	}
+	require.NoError(json.Unmarshal(buf.Bytes(), &out))
+	var keys []string
+	for k := range out.Languages[1] {
+		keys = append(keys, k)
+	}
+	require.ElementsMatch([]string{
+		"language", "files", "nodes", "no_roles", "no_positions", "roles", "role_sets", "internal_types", "no_semantic_role",
+	}, keys)
+}
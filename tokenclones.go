@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// TokenClones finds duplicated token sequences in the analyzed files, as
+// done by PMD's Copy/Paste Detector:
+// https://pmd.github.io/latest/pmd_userdocs_cpd.html
+//
+// Windows of MinTokens tokens are compared using a Karp-Rabin rolling hash,
+// and every pair of equal windows is then extended for as long as the
+// tokens keep matching. The tokens are the ones returned by TokenNodes, so
+// they follow the order of the source code. When Normalize is set, every
+// identifier and literal token is replaced by a placeholder, so renamed
+// variables or changed constants are still detected.
+type TokenClones struct {
+	// MinTokens is the minimum length in tokens of the reported
+	// duplications.
+	MinTokens int
+	// Normalize ignores the text of identifiers and literals.
+	Normalize bool
+}
+
+// TokenClonesOptions are the options of TokenClones.
+type TokenClonesOptions struct {
+	MinTokens int  `long:"min-tokens" description:"minimum length of the duplications in tokens" default:"100"`
+	Normalize bool `long:"normalize" description:"ignore the text of identifiers and literals"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "token-clones",
+		Description: "Run token-based code clone detection",
+		Options:     func() interface{} { return &TokenClonesOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*TokenClonesOptions)
+			return TokenClones{MinTokens: o.MinTokens, Normalize: o.Normalize}, nil
+		},
+	})
+}
+
+// TokenDuplication is a sequence of tokens found in more than one place.
+type TokenDuplication struct {
+	Tokens      int
+	Occurrences []*TokenRange
+}
+
+// TokenRange is a sequence of tokens in a file.
+type TokenRange struct {
+	File string
+	// StartLine and EndLine are the lines spanned by the tokens, zero if
+	// none of them has a position.
+	StartLine int
+	EndLine   int
+
+	file  int
+	start int
+}
+
+func (tc TokenClones) Exec(n *uast.Node) error {
+	return tc.ExecFiles([]*File{{UAST: n}})
+}
+
+func (tc TokenClones) ExecFiles(files []*File) error {
+	for _, dup := range tc.Find(files) {
+		fmt.Printf("Duplication:%d tokens, Occurrences:%d\n", dup.Tokens, len(dup.Occurrences))
+		for _, occurrence := range dup.Occurrences {
+			fmt.Print("\t", occurrence)
+		}
+	}
+	return nil
+}
+
+func (tr *TokenRange) String() string {
+	return fmt.Sprintf("File:%s, Lines:%d-%d\n", tr.File, tr.StartLine, tr.EndLine)
+}
+
+const (
+	normalizedIdentifier = "$id"
+	normalizedLiteral    = "$literal"
+	rollingHashBase      = 1000003
+)
+
+// Find returns the duplications found in the files, longest first.
+func (tc TokenClones) Find(files []*File) []*TokenDuplication {
+	window := tc.MinTokens
+	if window < 1 {
+		window = 1
+	}
+
+	nodes := make([][]*uast.Node, len(files))
+	tokens := make([][]uint64, len(files))
+	for i, file := range files {
+		nodes[i] = TokenNodes(file.UAST)
+		for _, node := range nodes[i] {
+			tokens[i] = append(tokens[i], tc.tokenHash(node))
+		}
+	}
+
+	// highest is rollingHashBase^(window-1), to remove the first token of
+	// the window when rolling it
+	highest := uint64(1)
+	for i := 1; i < window; i++ {
+		highest *= rollingHashBase
+	}
+
+	type position struct{ file, start int }
+	windows := make(map[uint64][]position)
+	for f, seq := range tokens {
+		if len(seq) < window {
+			continue
+		}
+		var hash uint64
+		for i, token := range seq {
+			if i >= window {
+				hash -= seq[i-window] * highest
+			}
+			hash = hash*rollingHashBase + token
+			if i >= window-1 {
+				windows[hash] = append(windows[hash], position{f, i - window + 1})
+			}
+		}
+	}
+
+	equal := func(a, b position) bool {
+		return a.start < len(tokens[a.file]) && b.start < len(tokens[b.file]) &&
+			tokens[a.file][a.start] == tokens[b.file][b.start]
+	}
+
+	// duplications are indexed by the hash of their tokens, and their
+	// occurrences by file and start
+	duplications := make(map[[2]uint64]*TokenDuplication)
+	occurrences := make(map[[2]uint64]map[position]bool)
+	var result []*TokenDuplication
+	for _, positions := range windows {
+		for i, a := range positions {
+			for _, b := range positions[i+1:] {
+				// only pairs that can't be extended backwards, the
+				// rest are part of a longer duplication
+				if a.start > 0 && b.start > 0 && equal(position{a.file, a.start - 1}, position{b.file, b.start - 1}) {
+					continue
+				}
+
+				length := 0
+				for equal(position{a.file, a.start + length}, position{b.file, b.start + length}) {
+					if a.file == b.file && a.start+length >= b.start {
+						break
+					}
+					length++
+				}
+				if length < window {
+					continue
+				}
+
+				key := [2]uint64{sequenceHash(tokens[a.file][a.start : a.start+length]), uint64(length)}
+				dup, ok := duplications[key]
+				if !ok {
+					dup = &TokenDuplication{Tokens: length}
+					duplications[key] = dup
+					occurrences[key] = make(map[position]bool)
+					result = append(result, dup)
+				}
+				for _, p := range []position{a, b} {
+					if !occurrences[key][p] {
+						occurrences[key][p] = true
+						dup.Occurrences = append(dup.Occurrences, tokenRange(files, nodes, p.file, p.start, length))
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Tokens != result[j].Tokens {
+			return result[i].Tokens > result[j].Tokens
+		}
+		a, b := result[i].Occurrences[0], result[j].Occurrences[0]
+		return a.file < b.file || a.file == b.file && a.start < b.start
+	})
+	for _, dup := range result {
+		sort.SliceStable(dup.Occurrences, func(i, j int) bool {
+			a, b := dup.Occurrences[i], dup.Occurrences[j]
+			return a.file < b.file || a.file == b.file && a.start < b.start
+		})
+	}
+	return result
+}
+
+func (tc TokenClones) tokenHash(n *uast.Node) uint64 {
+	token := n.Token
+	if tc.Normalize {
+		if containsRoles(n, []uast.Role{uast.Identifier}, nil) {
+			token = normalizedIdentifier
+		} else if containsRoles(n, []uast.Role{uast.Literal}, nil) {
+			token = normalizedLiteral
+		}
+	}
+	digest := fnv.New64a()
+	digest.Write([]byte(token))
+	return digest.Sum64()
+}
+
+func sequenceHash(tokens []uint64) uint64 {
+	digest := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, token := range tokens {
+		binary.LittleEndian.PutUint64(buf, token)
+		digest.Write(buf)
+	}
+	return digest.Sum64()
+}
+
+func tokenRange(files []*File, nodes [][]*uast.Node, file, start, length int) *TokenRange {
+	tr := &TokenRange{File: files[file].Path, file: file, start: start}
+	for _, node := range nodes[file][start : start+length] {
+		for _, pos := range []*uast.Position{node.StartPosition, node.EndPosition} {
+			if pos == nil || pos.Line == 0 {
+				continue
+			}
+			if tr.StartLine == 0 || int(pos.Line) < tr.StartLine {
+				tr.StartLine = int(pos.Line)
+			}
+			if int(pos.Line) > tr.EndLine {
+				tr.EndLine = int(pos.Line)
+			}
+		}
+	}
+	return tr
+}
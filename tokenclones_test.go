@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// tokenFile builds a flat UAST with a token per line. Tokens starting with
+// a digit are literals and the ones starting with a letter identifiers.
+func tokenFile(tokens ...string) *uast.Node {
+	n := &uast.Node{InternalType: "File"}
+	for i, token := range tokens {
+		child := &uast.Node{InternalType: "Token", Token: token, StartPosition: &uast.Position{Line: uint32(i + 1)}}
+		switch {
+		case token[0] >= '0' && token[0] <= '9':
+			child.Roles = []uast.Role{uast.Literal}
+		case token[0] >= 'a' && token[0] <= 'z':
+			child.Roles = []uast.Role{uast.Identifier}
+		}
+		n.Children = append(n.Children, child)
+	}
+	return n
+}
+
+func TestTokenNodes(t *testing.T) {
+	require := require.New(t)
+
+	n := &uast.Node{InternalType: "Binary", Roles: []uast.Role{uast.Infix}, Token: "+", Children: []*uast.Node{
+		{InternalType: "Left", Token: "a"},
+		{InternalType: "Right", Token: "b"},
+	}}
+	nodes := TokenNodes(n)
+	require.Len(nodes, 3)
+	require.Equal("Left", nodes[0].InternalType)
+	require.Equal([]string{"a", "+", "b"}, Tokens(n))
+}
+
+func TestTokenClones(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{
+		{Path: "a.js", UAST: tokenFile("x", "=", "f", "(", "1", ")", ";", "y", "=", "f", "(", "1", ")", ";")},
+		{Path: "b.js", UAST: tokenFile("{", "z", "=", "g", "(", "2", ")", ";", "}")},
+	}
+
+	dups := TokenClones{MinTokens: 5}.Find(files)
+	require.Equal([]*TokenDuplication{{Tokens: 6, Occurrences: []*TokenRange{
+		{File: "a.js", StartLine: 2, EndLine: 7, file: 0, start: 1},
+		{File: "a.js", StartLine: 9, EndLine: 14, file: 0, start: 8},
+	}}}, dups)
+
+	dups = TokenClones{MinTokens: 5, Normalize: true}.Find(files)
+	require.Equal([]*TokenDuplication{{Tokens: 7, Occurrences: []*TokenRange{
+		{File: "a.js", StartLine: 1, EndLine: 7, file: 0, start: 0},
+		{File: "a.js", StartLine: 8, EndLine: 14, file: 0, start: 7},
+		{File: "b.js", StartLine: 2, EndLine: 8, file: 1, start: 1},
+	}}}, dups)
+
+	require.Len(TokenClones{MinTokens: 8, Normalize: true}.Find(files), 0)
+}
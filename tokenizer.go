@@ -1,19 +1,224 @@
 package tools
 
-import "gopkg.in/bblfsh/sdk.v1/uast"
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
 
-type Tokenizer struct{}
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Tokenizer prints the tokens of the code. By default they are printed one
+// per line, while the jsonl and csv formats print every token with its
+// position, roles, internal type and depth. Filter selects the tokens to
+// print. When Split is set, identifiers are split into their parts, printed
+// separated by spaces in the text format and as an extra field otherwise.
+type Tokenizer struct {
+	// Format is the output format: text, jsonl or csv.
+	Format string
+	Filter TokenFilter
+	Split  *IdentifierSplitter
+}
+
+// TokenOptions are the options of the tools working on token streams.
+type TokenOptions struct {
+	Roles        []string `long:"role" description:"only use the tokens of nodes with this role, can be repeated"`
+	ExcludeRoles []string `long:"exclude-role" description:"don't use the tokens of nodes with this role, can be repeated"`
+	Split        bool     `long:"split" description:"split identifiers into lower case parts"`
+	KeepCase     bool     `long:"keep-case" description:"don't lowercase the parts of split identifiers"`
+	Stem         bool     `long:"stem" description:"stem the parts of split identifiers"`
+	StopWords    bool     `long:"stop-words" description:"drop common English words from split identifiers"`
+}
+
+// Filter returns the filter of the tokens of the options.
+func (o *TokenOptions) Filter() (TokenFilter, error) {
+	roles, err := ParseRoles(o.Roles)
+	if err != nil {
+		return TokenFilter{}, err
+	}
+	excludeRoles, err := ParseRoles(o.ExcludeRoles)
+	if err != nil {
+		return TokenFilter{}, err
+	}
+	return TokenFilter{Roles: roles, ExcludeRoles: excludeRoles}, nil
+}
+
+// Splitter returns the identifier splitter of the options, nil if
+// identifiers are not split.
+func (o *TokenOptions) Splitter() *IdentifierSplitter {
+	if !o.Split {
+		return nil
+	}
+	splitter := &IdentifierSplitter{Lowercase: !o.KeepCase, Stem: o.Stem}
+	if o.StopWords {
+		splitter.StopWords = DefaultStopWords
+	}
+	return splitter
+}
+
+// TokenizerOptions are the options of Tokenizer.
+type TokenizerOptions struct {
+	TokenOptions
+	Format string `long:"format" description:"output format: text, jsonl or csv" default:"text"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "tokenizer",
+		Description: "Run tokenizer tool",
+		Options:     func() interface{} { return &TokenizerOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*TokenizerOptions)
+			filter, err := o.Filter()
+			if err != nil {
+				return nil, err
+			}
+			return Tokenizer{Format: o.Format, Filter: filter, Split: o.Splitter()}, nil
+		},
+	})
+}
 
 func (t Tokenizer) Exec(node *uast.Node) error {
-	for _, token := range Tokens(node) {
-		print(token)
+	return t.ExecFiles([]*File{{UAST: node}})
+}
+
+func (t Tokenizer) ExecFiles(files []*File) error {
+	switch t.Format {
+	case "", "text":
+		for _, file := range files {
+			for _, token := range t.tokens(file) {
+				if token.Parts == nil {
+					fmt.Println(token.Text)
+				} else if len(token.Parts) > 0 {
+					fmt.Println(strings.Join(token.Parts, " "))
+				}
+			}
+		}
+		return nil
+	case "jsonl":
+		return t.writeJSONL(os.Stdout, files)
+	case "csv":
+		return t.writeCSV(os.Stdout, files)
+	default:
+		return ErrUnknownFormat.New(t.Format)
 	}
-	return nil
+}
+
+func (t Tokenizer) tokens(file *File) []*Token {
+	tokens := TokensOf(file.UAST, t.Filter)
+	if t.Split != nil {
+		t.Split.SplitTokens(tokens)
+	}
+	return tokens
 }
 
 // Tokens returns a slice of tokens contained in the node.
 func Tokens(n *uast.Node) []string {
 	var tokens []string
+	for _, node := range TokenNodes(n) {
+		tokens = append(tokens, node.Token)
+	}
+	return tokens
+}
+
+// TokenNodes returns the nodes with a token contained in the node, in the
+// same order as Tokens. Nodes with the Infix or Postfix roles are returned
+// without their children, as they are reordered to follow the source code.
+func TokenNodes(n *uast.Node) []*uast.Node {
+	var nodes []*uast.Node
+	walkTokens(n, func(p uast.Path) {
+		nodes = append(nodes, p.Node())
+	})
+	return nodes
+}
+
+// Token is a token of the code along with the node it comes from.
+type Token struct {
+	Text string
+	// Start and End are the positions of the node, nil if unknown.
+	Start *uast.Position
+	End   *uast.Position
+	Roles []uast.Role
+	// InternalType is the internal type of the node.
+	InternalType string
+	// Depth is the depth of the node in the tree, 0 being the root.
+	Depth int
+	// Parts are the parts of an identifier, set by
+	// IdentifierSplitter.SplitTokens.
+	Parts []string
+}
+
+// TokenFilter selects tokens by the roles of their nodes.
+type TokenFilter struct {
+	// Roles, if not empty, keeps only the tokens whose node has any of
+	// them.
+	Roles []uast.Role
+	// ExcludeRoles drops the tokens whose node has any of them.
+	ExcludeRoles []uast.Role
+}
+
+// Match returns whether the filter keeps the token of the node.
+func (f TokenFilter) Match(n *uast.Node) bool {
+	for _, r := range f.ExcludeRoles {
+		if containsRoles(n, []uast.Role{r}, nil) {
+			return false
+		}
+	}
+	if len(f.Roles) == 0 {
+		return true
+	}
+	for _, r := range f.Roles {
+		if containsRoles(n, []uast.Role{r}, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokensOf returns the tokens contained in the node that match the filter,
+// in the same order as Tokens.
+func TokensOf(n *uast.Node, filter TokenFilter) []*Token {
+	var tokens []*Token
+	walkTokens(n, func(p uast.Path) {
+		node := p.Node()
+		if !filter.Match(node) {
+			return
+		}
+		tokens = append(tokens, &Token{
+			Text:         node.Token,
+			Start:        node.StartPosition,
+			End:          node.EndPosition,
+			Roles:        node.Roles,
+			InternalType: node.InternalType,
+			Depth:        tokenDepth(p),
+		})
+	})
+	return tokens
+}
+
+// tokenDepth returns the depth of the node of a path given by walkTokens.
+// Nodes with the Infix or Postfix roles are copied without children right
+// under the original node, so the copies have the depth of the original.
+func tokenDepth(p uast.Path) int {
+	depth := len(p) - 1
+	if depth == 0 {
+		return 0
+	}
+	for _, child := range p[depth-1].Children {
+		if child == p.Node() {
+			return depth
+		}
+	}
+	return depth - 1
+}
+
+// walkTokens calls fn with the path of every node with a token.
+func walkTokens(n *uast.Node, fn func(uast.Path)) {
 	iter := uast.NewOrderPathIter(uast.NewPath(n))
 	for {
 		p := iter.Next()
@@ -21,10 +226,195 @@ func Tokens(n *uast.Node) []string {
 			break
 		}
 
-		n := p.Node()
-		if n.Token != "" {
-			tokens = append(tokens, n.Token)
+		if p.Node().Token != "" {
+			fn(p)
 		}
 	}
-	return tokens
+}
+
+type tokenJSON struct {
+	File         string   `json:"file,omitempty"`
+	Text         string   `json:"text"`
+	StartOffset  *uint32  `json:"start_offset,omitempty"`
+	StartLine    *uint32  `json:"start_line,omitempty"`
+	StartCol     *uint32  `json:"start_col,omitempty"`
+	EndOffset    *uint32  `json:"end_offset,omitempty"`
+	EndLine      *uint32  `json:"end_line,omitempty"`
+	EndCol       *uint32  `json:"end_col,omitempty"`
+	Roles        []string `json:"roles"`
+	InternalType string   `json:"internal_type"`
+	Depth        int      `json:"depth"`
+	Parts        []string `json:"parts,omitempty"`
+}
+
+func (t Tokenizer) writeJSONL(w io.Writer, files []*File) error {
+	enc := json.NewEncoder(w)
+	for _, file := range files {
+		for _, token := range t.tokens(file) {
+			out := &tokenJSON{
+				File:         file.Path,
+				Text:         token.Text,
+				Roles:        roleNames(token.Roles),
+				InternalType: token.InternalType,
+				Depth:        token.Depth,
+				Parts:        token.Parts,
+			}
+			if token.Start != nil {
+				out.StartOffset, out.StartLine, out.StartCol = &token.Start.Offset, &token.Start.Line, &token.Start.Col
+			}
+			if token.End != nil {
+				out.EndOffset, out.EndLine, out.EndCol = &token.End.Offset, &token.End.Line, &token.End.Col
+			}
+			if err := enc.Encode(out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var tokensCSVHeader = []string{
+	"file", "text",
+	"start_offset", "start_line", "start_col",
+	"end_offset", "end_line", "end_col",
+	"roles", "internal_type", "depth", "parts",
+}
+
+func (t Tokenizer) writeCSV(w io.Writer, files []*File) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(tokensCSVHeader); err != nil {
+		return err
+	}
+	position := func(pos *uast.Position) []string {
+		if pos == nil {
+			return []string{"", "", ""}
+		}
+		return []string{
+			strconv.Itoa(int(pos.Offset)),
+			strconv.Itoa(int(pos.Line)),
+			strconv.Itoa(int(pos.Col)),
+		}
+	}
+	for _, file := range files {
+		for _, token := range t.tokens(file) {
+			record := []string{file.Path, token.Text}
+			record = append(record, position(token.Start)...)
+			record = append(record, position(token.End)...)
+			record = append(record,
+				strings.Join(roleNames(token.Roles), "|"),
+				token.InternalType,
+				strconv.Itoa(token.Depth),
+				strings.Join(token.Parts, " "),
+			)
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// DefaultStopWords are common English words carrying little meaning in
+// identifiers.
+var DefaultStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+// IdentifierSplitter splits identifiers into normalized parts, as used by
+// machine learning models on source code: parseHTTPRequest becomes parse,
+// http and request.
+type IdentifierSplitter struct {
+	// Lowercase turns every part into lower case.
+	Lowercase bool
+	// Stem reduces every part to its stem with Stem, in lower case.
+	Stem bool
+	// StopWords are the lower case parts to drop, e.g. DefaultStopWords.
+	StopWords map[string]bool
+}
+
+// Split returns the normalized parts of an identifier, as split by
+// SplitIdentifier.
+func (s *IdentifierSplitter) Split(identifier string) []string {
+	parts := []string{}
+	for _, part := range SplitIdentifier(identifier) {
+		lower := strings.ToLower(part)
+		if s.StopWords[lower] {
+			continue
+		}
+		switch {
+		case s.Stem:
+			part = Stem(lower)
+		case s.Lowercase:
+			part = lower
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// SplitTokens sets the parts of the tokens whose node has the Identifier
+// role.
+func (s *IdentifierSplitter) SplitTokens(tokens []*Token) {
+	for _, token := range tokens {
+		for _, r := range token.Roles {
+			if r == uast.Identifier {
+				token.Parts = s.Split(token.Text)
+				break
+			}
+		}
+	}
+}
+
+// SplitIdentifier splits an identifier into its parts, keeping their case.
+// Parts are separated by any character which is not a letter or a digit, as
+// in snake_case, by a lower to upper case change, as in camelCase, and
+// between letters and digits. An all caps acronym is a single part, ending
+// before the capital letter of the next one, as in HTTPRequest, and keeping
+// a plural s, as in URLs.
+func SplitIdentifier(identifier string) []string {
+	var parts []string
+	runes := []rune(identifier)
+	start := -1
+	for i, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			if start >= 0 {
+				parts = append(parts, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && isIdentifierBoundary(runes, i) {
+			parts = append(parts, string(runes[start:i]))
+			start = -1
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, string(runes[start:]))
+	}
+	return parts
+}
+
+// isIdentifierBoundary tells if a new part starts at i, with runes[i-1]
+// being a letter or a digit.
+func isIdentifierBoundary(runes []rune, i int) bool {
+	prev, c := runes[i-1], runes[i]
+	switch {
+	case unicode.IsDigit(prev) != unicode.IsDigit(c):
+		return true
+	case unicode.IsLower(prev) && unicode.IsUpper(c):
+		return true
+	case unicode.IsUpper(prev) && unicode.IsUpper(c) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+		// the last capital letter of an acronym starts the next part,
+		// unless followed by a plural s
+		pluralS := runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2]))
+		return !pluralS
+	}
+	return false
 }
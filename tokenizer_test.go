@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func tokenizerFixture() *uast.Node {
+	return &uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "Comment", Roles: []uast.Role{uast.Comment}, Token: "// sum"},
+		{InternalType: "Assign", Roles: []uast.Role{uast.Assignment}, Token: "=", Children: []*uast.Node{
+			{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: "x",
+				StartPosition: &uast.Position{Offset: 7, Line: 2, Col: 1}, EndPosition: &uast.Position{Offset: 8, Line: 2, Col: 2}},
+			{InternalType: "Number", Roles: []uast.Role{uast.Literal, uast.Number}, Token: "1"},
+		}},
+	}}
+}
+
+func TestTokensOf(t *testing.T) {
+	require := require.New(t)
+
+	n := tokenizerFixture()
+	tokens := TokensOf(n, TokenFilter{})
+	require.Len(tokens, 4)
+	require.Equal(&Token{
+		Text:         "x",
+		Start:        &uast.Position{Offset: 7, Line: 2, Col: 1},
+		End:          &uast.Position{Offset: 8, Line: 2, Col: 2},
+		Roles:        []uast.Role{uast.Identifier},
+		InternalType: "Name",
+		Depth:        2,
+	}, tokens[2])
+
+	var texts []string
+	for _, token := range TokensOf(n, TokenFilter{ExcludeRoles: []uast.Role{uast.Comment}}) {
+		texts = append(texts, token.Text)
+	}
+	require.Equal([]string{"=", "x", "1"}, texts)
+
+	texts = nil
+	for _, token := range TokensOf(n, TokenFilter{Roles: []uast.Role{uast.Identifier, uast.Literal}}) {
+		texts = append(texts, token.Text)
+	}
+	require.Equal([]string{"x", "1"}, texts)
+}
+
+func TestTokensOfInfix(t *testing.T) {
+	require := require.New(t)
+
+	n := &uast.Node{InternalType: "File", Children: []*uast.Node{
+		{InternalType: "Plus", Roles: []uast.Role{uast.Infix}, Token: "+", Children: []*uast.Node{
+			{InternalType: "Name", Token: "a"},
+			{InternalType: "Name", Token: "b"},
+		}},
+	}}
+
+	var texts []string
+	var depths []int
+	for _, token := range TokensOf(n, TokenFilter{}) {
+		texts = append(texts, token.Text)
+		depths = append(depths, token.Depth)
+	}
+	require.Equal([]string{"a", "+", "b"}, texts)
+	require.Equal([]int{2, 1, 2}, depths)
+}
+
+func TestWriteTokens(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{{Path: "a.py", UAST: tokenizerFixture()}}
+	tokenizer := Tokenizer{
+		Filter: TokenFilter{Roles: []uast.Role{uast.Identifier, uast.Literal}},
+		Split:  &IdentifierSplitter{Lowercase: true},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(tokenizer.writeJSONL(buf, files))
+	require.Equal(`{"file":"a.py","text":"x","start_offset":7,"start_line":2,"start_col":1,"end_offset":8,"end_line":2,"end_col":2,"roles":["Identifier"],"internal_type":"Name","depth":2,"parts":["x"]}
+{"file":"a.py","text":"1","roles":["Literal","Number"],"internal_type":"Number","depth":2}
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(tokenizer.writeCSV(buf, files))
+	require.Equal(`file,text,start_offset,start_line,start_col,end_offset,end_line,end_col,roles,internal_type,depth,parts
+a.py,x,7,2,1,8,2,2,Identifier,Name,2,x
+a.py,1,,,,,,,Literal|Number,Number,2,
+`, buf.String())
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	require := require.New(t)
+
+	cases := map[string][]string{
+		"parseHTTPRequest": {"parse", "HTTP", "Request"},
+		"snake_case":       {"snake", "case"},
+		"PascalCase":       {"Pascal", "Case"},
+		"MAX_VALUE":        {"MAX", "VALUE"},
+		"__init__":         {"init"},
+		"utf8Decode":       {"utf", "8", "Decode"},
+		"getURLs":          {"get", "URLs"},
+		"IOError":          {"IO", "Error"},
+		"x":                {"x"},
+		"_":                nil,
+	}
+	for identifier, expected := range cases {
+		require.Equal(expected, SplitIdentifier(identifier), identifier)
+	}
+
+	splitter := &IdentifierSplitter{Lowercase: true}
+	require.Equal([]string{"parse", "http", "request"}, splitter.Split("parseHTTPRequest"))
+
+	splitter = &IdentifierSplitter{Stem: true, StopWords: DefaultStopWords}
+	require.Equal([]string{"connect", "user"}, splitter.Split("connectionsOfTheUsers"))
+	require.Equal([]string{}, splitter.Split("the"))
+
+	tokens := TokensOf(tokenizerFixture(), TokenFilter{})
+	(&IdentifierSplitter{}).SplitTokens(tokens)
+	require.Nil(tokens[1].Parts)
+	require.Equal([]string{"x"}, tokens[2].Parts)
+}
+
+func TestStem(t *testing.T) {
+	require := require.New(t)
+
+	cases := map[string]string{
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"cats":        "cat",
+		"agreed":      "agre",
+		"plastered":   "plaster",
+		"motoring":    "motor",
+		"hopping":     "hop",
+		"filing":      "file",
+		"happy":       "happi",
+		"relational":  "relat",
+		"conditional": "condit",
+		"hopefulness": "hope",
+		"adjustment":  "adjust",
+		"adoption":    "adopt",
+		"controll":    "control",
+		"generalize":  "gener",
+		"is":          "is",
+	}
+	for word, expected := range cases {
+		require.Equal(expected, Stem(word), word)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{"LessThanOrEqual", "lessthanorequal", "LESS_THAN_OR_EQUAL"} {
+		role, err := ParseRole(name)
+		require.NoError(err)
+		require.Equal(uast.LessThanOrEqual, role)
+	}
+
+	_, err := ParseRole("Invalid")
+	require.True(ErrUnknownRole.Is(err))
+	_, err = ParseRoles([]string{"If", "Foo"})
+	require.True(ErrUnknownRole.Is(err))
+}
@@ -1,6 +1,11 @@
 package tools
 
-import "gopkg.in/bblfsh/sdk.v1/uast"
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
 
 // Tooler is an interface which can be implemented by any supported tool.
 // When implemented, the Exec method will be called with a UAST root node.
@@ -9,3 +14,59 @@ type Tooler interface {
 	// to the command handler
 	Exec(*uast.Node) error
 }
+
+// MultiTooler is an interface which can be implemented by tools that need
+// to look at all the files of a run at once, e.g. to resolve references
+// between them.
+type MultiTooler interface {
+	// ExecFiles will be called once with every parsed file. The error will
+	// be passed to the command handler
+	ExecFiles([]*File) error
+}
+
+// File is a parsed source file.
+type File struct {
+	// Path is the path of the file as given to the command.
+	Path string
+	// Language is the language of the file, empty when it was left to
+	// the server to detect it.
+	Language string
+	// UAST is the root node of the file.
+	UAST *uast.Node
+	// Content is the source code of the file, empty if unknown.
+	Content string
+}
+
+// extensionLanguages are the languages of the most common file extensions.
+var extensionLanguages = map[string]string{
+	"py":   "python",
+	"js":   "javascript",
+	"ts":   "typescript",
+	"rb":   "ruby",
+	"cs":   "csharp",
+	"sh":   "bash",
+	"c":    "cpp",
+	"cc":   "cpp",
+	"cpp":  "cpp",
+	"h":    "cpp",
+	"hpp":  "cpp",
+	"java": "java",
+	"go":   "go",
+	"php":  "php",
+}
+
+// fileLanguage returns the language of the file, guessed from its extension
+// if unknown, or "unknown" if it has none.
+func fileLanguage(file *File) string {
+	if file.Language != "" {
+		return file.Language
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Path), "."))
+	if lang, ok := extensionLanguages[ext]; ok {
+		return lang
+	}
+	if ext != "" {
+		return ext
+	}
+	return "unknown"
+}
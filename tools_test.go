@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/protocol"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// readFixture decodes a ParseResponse saved as JSON and returns its UAST.
+func readFixture(t *testing.T, name string) *uast.Node {
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+
+	res := &protocol.ParseResponse{}
+	err = json.NewDecoder(bufio.NewReader(file)).Decode(res)
+	require.NoError(t, err)
+	return res.UAST
+}
@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+// Vocab builds the vocabulary of the analyzed files: the frequency of every
+// term, their token n-grams, and optionally the TF-IDF vector of every file.
+//
+// The terms of a file are the tokens matching Filter, in the order returned
+// by TokensOf, with identifiers replaced by their parts when Split is set.
+// N-grams are sequences of up to NGrams consecutive terms of a file, joined
+// by spaces, and are counted as terms too.
+//
+// The TF-IDF weight of a term in a file is its count over the number of
+// terms of the file, times the smoothed inverse document frequency
+// 1 + ln((1 + N) / (1 + df)), N being the number of files and df the ones
+// containing the term, so terms found in every file keep a small weight.
+type Vocab struct {
+	// Format is the output format: text, json or csv. The csv format
+	// writes the vocabulary, or the TF-IDF weights if TFIDF is set.
+	Format string
+	Filter TokenFilter
+	Split  *IdentifierSplitter
+	// NGrams is the longest n-gram to count, 1 or less for single terms.
+	NGrams int
+	// MinCount drops the terms found less times than it in all the files.
+	MinCount int
+	// TFIDF computes the TF-IDF vector of every file.
+	TFIDF bool
+}
+
+// VocabOptions are the options of Vocab.
+type VocabOptions struct {
+	TokenOptions
+	Format   string `long:"format" description:"output format: text, json or csv" default:"text"`
+	NGrams   int    `long:"ngrams" description:"longest token n-gram to count" default:"1"`
+	MinCount int    `long:"min-count" description:"minimum number of occurrences of the reported terms" default:"1"`
+	TFIDF    bool   `long:"tfidf" description:"compute the TF-IDF vector of every file, written instead of the vocabulary with the csv format"`
+}
+
+func init() {
+	Register(&ToolInfo{
+		Name:        "vocab",
+		Description: "Run vocabulary, n-gram and TF-IDF extraction",
+		Options:     func() interface{} { return &VocabOptions{} },
+		New: func(env *ToolEnv) (Tooler, error) {
+			o := env.Options.(*VocabOptions)
+			filter, err := o.Filter()
+			if err != nil {
+				return nil, err
+			}
+			return Vocab{
+				Format:   o.Format,
+				Filter:   filter,
+				Split:    o.Splitter(),
+				NGrams:   o.NGrams,
+				MinCount: o.MinCount,
+				TFIDF:    o.TFIDF,
+			}, nil
+		},
+	})
+}
+
+// VocabData is the vocabulary of a set of files.
+type VocabData struct {
+	// Terms are sorted by count, from the most frequent.
+	Terms []*Term `json:"terms"`
+	// Files are the TF-IDF vectors of every file, if requested.
+	Files []*TermVector `json:"files,omitempty"`
+}
+
+// Term is a term or an n-gram of the vocabulary.
+type Term struct {
+	Text string `json:"text"`
+	// N is the number of terms of an n-gram, 1 for single terms.
+	N     int `json:"n"`
+	Count int `json:"count"`
+	// Files is the number of files containing the term.
+	Files int `json:"files"`
+}
+
+// TermVector are the TF-IDF weights of the terms of a file.
+type TermVector struct {
+	File    string             `json:"file"`
+	Weights map[string]float64 `json:"tfidf"`
+}
+
+func (v Vocab) Exec(n *uast.Node) error {
+	return v.ExecFiles([]*File{{UAST: n}})
+}
+
+func (v Vocab) ExecFiles(files []*File) error {
+	data := v.Build(files)
+	switch v.Format {
+	case "", "text":
+		for _, term := range data.Terms {
+			fmt.Print(term)
+		}
+		for _, vector := range data.Files {
+			for _, term := range vector.sortedTerms() {
+				fmt.Printf("File:%s, Term:%s, TFIDF:%.4f\n", vector.File, term, vector.Weights[term])
+			}
+		}
+		return nil
+	case "json":
+		return data.WriteJSON(os.Stdout)
+	case "csv":
+		return data.WriteCSV(os.Stdout)
+	default:
+		return ErrUnknownFormat.New(v.Format)
+	}
+}
+
+func (t *Term) String() string {
+	return fmt.Sprintf("Term:%s, N:%d, Count:%d, Files:%d\n", t.Text, t.N, t.Count, t.Files)
+}
+
+// WriteJSON writes the vocabulary as a JSON document.
+func (vd *VocabData) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(vd)
+}
+
+// WriteCSV writes the TF-IDF weights as rows of file, term, n and weight if
+// there are any, or the vocabulary as rows of term, n, count and files.
+func (vd *VocabData) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if vd.Files != nil {
+		n := make(map[string]int, len(vd.Terms))
+		for _, term := range vd.Terms {
+			n[term.Text] = term.N
+		}
+		if err := out.Write([]string{"file", "term", "n", "tfidf"}); err != nil {
+			return err
+		}
+		for _, vector := range vd.Files {
+			for _, term := range vector.sortedTerms() {
+				weight := strconv.FormatFloat(vector.Weights[term], 'f', -1, 64)
+				if err := out.Write([]string{vector.File, term, strconv.Itoa(n[term]), weight}); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		if err := out.Write([]string{"term", "n", "count", "files"}); err != nil {
+			return err
+		}
+		for _, term := range vd.Terms {
+			record := []string{term.Text, strconv.Itoa(term.N), strconv.Itoa(term.Count), strconv.Itoa(term.Files)}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// sortedTerms returns the terms of the vector by weight, from the highest.
+func (tv *TermVector) sortedTerms() []string {
+	terms := make([]string, 0, len(tv.Weights))
+	for term := range tv.Weights {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		a, b := tv.Weights[terms[i]], tv.Weights[terms[j]]
+		return a > b || a == b && terms[i] < terms[j]
+	})
+	return terms
+}
+
+// Terms returns the tokens contained in the node that match the filter, with
+// identifiers replaced by their parts if split is not nil.
+func Terms(n *uast.Node, filter TokenFilter, split *IdentifierSplitter) []string {
+	tokens := TokensOf(n, filter)
+	if split != nil {
+		split.SplitTokens(tokens)
+	}
+
+	var terms []string
+	for _, token := range tokens {
+		if token.Parts != nil {
+			terms = append(terms, token.Parts...)
+		} else {
+			terms = append(terms, token.Text)
+		}
+	}
+	return terms
+}
+
+// Build returns the vocabulary of the files.
+func (v Vocab) Build(files []*File) *VocabData {
+	maxN := v.NGrams
+	if maxN < 1 {
+		maxN = 1
+	}
+
+	terms := make(map[string]*Term)
+	counts := make([]map[string]int, len(files))
+	totals := make([]int, len(files))
+	for i, file := range files {
+		counts[i] = make(map[string]int)
+		stream := Terms(file.UAST, v.Filter, v.Split)
+		for n := 1; n <= maxN; n++ {
+			for start := 0; start+n <= len(stream); start++ {
+				text := strings.Join(stream[start:start+n], " ")
+				term, ok := terms[text]
+				if !ok {
+					term = &Term{Text: text, N: n}
+					terms[text] = term
+				}
+				term.Count++
+				if counts[i][text] == 0 {
+					term.Files++
+				}
+				counts[i][text]++
+				totals[i]++
+			}
+		}
+	}
+
+	data := &VocabData{Terms: []*Term{}}
+	for _, term := range terms {
+		if term.Count >= v.MinCount {
+			data.Terms = append(data.Terms, term)
+		}
+	}
+	sort.Slice(data.Terms, func(i, j int) bool {
+		a, b := data.Terms[i], data.Terms[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.N != b.N {
+			return a.N < b.N
+		}
+		return a.Text < b.Text
+	})
+
+	if !v.TFIDF {
+		return data
+	}
+
+	data.Files = []*TermVector{}
+	for i, file := range files {
+		vector := &TermVector{File: file.Path, Weights: make(map[string]float64)}
+		for text, count := range counts[i] {
+			term := terms[text]
+			if term.Count < v.MinCount {
+				continue
+			}
+			tf := float64(count) / float64(totals[i])
+			idf := 1 + math.Log(float64(1+len(files))/float64(1+term.Files))
+			vector.Weights[text] = tf * idf
+		}
+		data.Files = append(data.Files, vector)
+	}
+	return data
+}
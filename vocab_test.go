@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v1/uast"
+)
+
+func vocabFile(path string, tokens ...string) *File {
+	n := &uast.Node{InternalType: "File"}
+	for _, token := range tokens {
+		n.Children = append(n.Children, &uast.Node{InternalType: "Name", Roles: []uast.Role{uast.Identifier}, Token: token})
+	}
+	return &File{Path: path, UAST: n}
+}
+
+func TestTerms(t *testing.T) {
+	require := require.New(t)
+
+	n := tokenizerFixture()
+	require.Equal([]string{"// sum", "=", "x", "1"}, Terms(n, TokenFilter{}, nil))
+
+	n = vocabFile("a.go", "getUserName", "id").UAST
+	require.Equal([]string{"get", "user", "name", "id"}, Terms(n, TokenFilter{}, &IdentifierSplitter{Lowercase: true}))
+}
+
+func TestVocab(t *testing.T) {
+	require := require.New(t)
+
+	files := []*File{
+		vocabFile("a.go", "getName", "name"),
+		vocabFile("b.go", "setName"),
+	}
+	vocab := Vocab{Split: &IdentifierSplitter{Lowercase: true}, NGrams: 2, TFIDF: true}
+	data := vocab.Build(files)
+
+	require.Equal([]*Term{
+		{Text: "name", N: 1, Count: 3, Files: 2},
+		{Text: "get", N: 1, Count: 1, Files: 1},
+		{Text: "set", N: 1, Count: 1, Files: 1},
+		{Text: "get name", N: 2, Count: 1, Files: 1},
+		{Text: "name name", N: 2, Count: 1, Files: 1},
+		{Text: "set name", N: 2, Count: 1, Files: 1},
+	}, data.Terms)
+
+	require.Len(data.Files, 2)
+	require.Equal("a.go", data.Files[0].File)
+	require.Len(data.Files[0].Weights, 4)
+	// a.go has 5 terms: get, name, name, get name and name name
+	require.InDelta(2.0/5, data.Files[0].Weights["name"], 1e-9)
+	require.InDelta(1.0/5*(1+math.Log(1.5)), data.Files[0].Weights["get"], 1e-9)
+	require.Equal([]string{"name", "get", "get name", "name name"}, data.Files[0].sortedTerms())
+
+	vocab = Vocab{MinCount: 2}
+	data = vocab.Build(files)
+	require.Empty(data.Terms)
+
+	buf := bytes.NewBuffer(nil)
+	data = Vocab{}.Build(files[1:])
+	require.NoError(data.WriteCSV(buf))
+	require.Equal("term,n,count,files\nsetName,1,1,1\n", buf.String())
+
+	buf.Reset()
+	data = Vocab{TFIDF: true}.Build(files[1:])
+	require.NoError(data.WriteCSV(buf))
+	require.Equal("file,term,n,tfidf\nb.go,setName,1,1\n", buf.String())
+}